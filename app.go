@@ -18,17 +18,22 @@ const (
 	methodList      = "/list"
 	methodSetStatus = "/set-status"
 	methodGetStatus = "/settings/status"
+	methodGetFields = "/settings/fields"
 )
 
 type App[T interface{}] struct {
-	url    string
-	stand  Stand
-	client *http.Client
-	header http.Header
-	method struct {
+	url       string
+	namespace string
+	code      string
+	stand     Stand
+	client    *http.Client
+	header    http.Header
+	cache     *appCache
+	method    struct {
 		create    string
 		list      string
 		getStatus string
+		getFields string
 	}
 }
 
@@ -36,8 +41,11 @@ type App[T interface{}] struct {
 func NewApp[T interface{}](settings Settings) App[T] {
 	url := settings.toAppUrl()
 	return App[T]{
-		stand: settings.Stand,
-		url:   url,
+		stand:     settings.Stand,
+		url:       url,
+		namespace: settings.Namespace,
+		code:      settings.Code,
+		cache:     newAppCache(settings.Cache),
 		client: &http.Client{
 			Timeout: time.Second * 5,
 		},
@@ -45,10 +53,12 @@ func NewApp[T interface{}](settings Settings) App[T] {
 			create    string
 			list      string
 			getStatus string
+			getFields string
 		}{
 			create:    fmt.Sprintf("%s%s", url, methodCreate),
 			list:      fmt.Sprintf("%s%s", url, methodList),
 			getStatus: fmt.Sprintf("%s%s", url, methodGetStatus),
+			getFields: fmt.Sprintf("%s%s", url, methodGetFields),
 		},
 	}
 }
@@ -78,17 +88,37 @@ func (app App[T]) Create(ctx context.Context, item T) (T, error) {
 		return nilT, wrap(ir.Error, ErrResponseNotSuccess)
 	}
 
+	app.store(ir.Item)
 	return ir.Item, nil
 
 }
 
-// GetByID получает экземпляр приложения с переданным id
-func (app App[T]) GetByID(ctx context.Context, id string) (T, error) {
+// GetByID получает экземпляр приложения с переданным id.
+// С опцией WithFields сервер вернет только указанные поля.
+// Если задан Settings.Cache, элемент (без WithFields) сначала ищется в кэше
+func (app App[T]) GetByID(ctx context.Context, id string, opts ...GetOption) (T, error) {
 	var nilT T
 	if len(id) != uuid4Len {
 		return nilT, wrap(id, ErrInvalidID)
 	}
 
+	o := getOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.fields) > 0 {
+		return app.getSelected(ctx, id, o.fields)
+	}
+	if app.cache != nil {
+		return app.getCached(ctx, id)
+	}
+	return app.get(ctx, id)
+}
+
+// get получает элемент с сервера через /get
+func (app App[T]) get(ctx context.Context, id string) (T, error) {
+
+	var nilT T
 	url := app.url + "/" + id + methodGet
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -134,12 +164,15 @@ func (app App[T]) Update(ctx context.Context, id string, item T) (T, error) {
 
 	ir, err := doRequest[itemResponse[T]](app.client, request)
 	if err != nil {
+		app.invalidate(id)
 		return nilT, err
 	}
 	if !ir.Success {
+		app.invalidate(id)
 		return nilT, wrap(ir.Error, ErrResponseNotSuccess)
 	}
 
+	app.store(ir.Item)
 	return ir.Item, nil
 }
 
@@ -169,12 +202,15 @@ func (app App[T]) SetStatus(ctx context.Context, id, code string) (T, error) {
 
 	ir, err := doRequest[itemResponse[T]](app.client, request)
 	if err != nil {
+		app.invalidate(id)
 		return nilT, err
 	}
 	if !ir.Success {
+		app.invalidate(id)
 		return nilT, wrap(ir.Error, ErrResponseNotSuccess)
 	}
 
+	app.store(ir.Item)
 	return ir.Item, nil
 }
 
@@ -0,0 +1,228 @@
+package e365_gateway
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache - хранилище элементов для GetByID и GetByIDs (см. Settings.Cache).
+// Ключи включают namespace и code приложения, поэтому один Cache можно использовать для нескольких приложений.
+// Реализация должна быть безопасной для параллельного использования (например, LRUCache, redis или memcached)
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// appCache - кэш элементов приложения. По ключу namespace/code/id хранится версия элемента (__version),
+// а сам элемент - по ключу namespace/code/id@version#тип. Поэтому ответ на медленный запрос со старой версией
+// не перезапишет более новую, сохраненную после изменения, а адаптеры одного приложения с разными типами
+// (SearchAs, NewDynamicApp) не получат элемент, сохраненный в виде другого типа
+type appCache struct {
+	cache Cache
+	group singleflight.Group
+	mu    sync.Mutex
+}
+
+func newAppCache(cache Cache) *appCache {
+	if cache == nil {
+		return nil
+	}
+	return &appCache{cache: cache}
+}
+
+// cacheFetchTimeout - ограничение времени запроса элемента при промахе кэша.
+// Запрос не отменяется вместе с контекстом вызова, т.к. его результат ждут и другие вызовы
+const cacheFetchTimeout = 30 * time.Second
+
+func (app App[T]) cacheKey(id string) string {
+	return app.namespace + "/" + app.code + "/" + id
+}
+
+// itemKey возвращает ключ элемента версии version в виде типа T
+func (app App[T]) itemKey(id, version string) string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return app.cacheKey(id) + "@" + version + "#" + t.PkgPath() + "." + t.String()
+}
+
+// cached возвращает элемент из кэша
+func (app App[T]) cached(id string) (T, bool) {
+	var item T
+	key := app.cacheKey(id)
+	version, ok := app.cache.cache.Get(key)
+	if !ok {
+		return item, false
+	}
+	bts, ok := app.cache.cache.Get(app.itemKey(id, string(version)))
+	if !ok {
+		return item, false
+	}
+	if err := json.Unmarshal(bts, &item); err != nil {
+		return item, false
+	}
+	return item, true
+}
+
+// store сохраняет элемент в кэше, если в нем нет более новой версии
+func (app App[T]) store(item T) {
+	if app.cache == nil {
+		return
+	}
+	c := commonOf(item)
+	if c.ID == "" {
+		return
+	}
+	if c.Version == 0 {
+		// без версии нельзя понять, какой ответ новее
+		app.invalidate(c.ID)
+		return
+	}
+	bts, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+
+	key := app.cacheKey(c.ID)
+	app.cache.mu.Lock()
+	defer app.cache.mu.Unlock()
+	if cur, ok := app.cache.cache.Get(key); ok {
+		if v, err := strconv.Atoi(string(cur)); err == nil && v > c.Version {
+			return
+		}
+	}
+	version := strconv.Itoa(c.Version)
+	app.cache.cache.Set(app.itemKey(c.ID, version), bts)
+	app.cache.cache.Set(key, []byte(version))
+}
+
+// invalidate удаляет элемент из кэша
+func (app App[T]) invalidate(id string) {
+	if app.cache == nil {
+		return
+	}
+	app.cache.mu.Lock()
+	defer app.cache.mu.Unlock()
+	app.cache.cache.Delete(app.cacheKey(id))
+}
+
+// getCached получает элемент из кэша, а при промахе - с сервера. Параллельные промахи по одному id
+// объединяются в один запрос. Запрос выполняется без отмены контекстом первого вызова (но не дольше
+// cacheFetchTimeout), а каждый вызов ждет результат не дольше, чем позволяет его контекст
+func (app App[T]) getCached(ctx context.Context, id string) (T, error) {
+	var item T
+	if item, ok := app.cached(id); ok {
+		return item, nil
+	}
+	fetch := app.cache.group.DoChan(app.itemKey(id, ""), func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheFetchTimeout)
+		defer cancel()
+		item, err := app.get(fetchCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		app.store(item)
+		// ожидающие получают копии элемента
+		return json.Marshal(item)
+	})
+	select {
+	case <-ctx.Done():
+		return item, ctx.Err()
+	case res := <-fetch:
+		if res.Err != nil {
+			return item, res.Err
+		}
+		if err := json.Unmarshal(res.Val.([]byte), &item); err != nil {
+			return item, wrap(err.Error(), ErrDecodeResponseBody)
+		}
+		return item, nil
+	}
+}
+
+// LRUCache - кэш в памяти процесса, который хранит не более size последних использованных значений
+// не дольше ttl (0 - без ограничения по времени)
+type LRUCache struct {
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRUCache создает кэш в памяти процесса
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expiresAt.IsZero() && c.now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &lruEntry{key: key, value: value}
+	if c.ttl > 0 {
+		e.expiresAt = c.now().Add(c.ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len возвращает кол-во значений в кэше (включая устаревшие, но еще не удаленные)
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
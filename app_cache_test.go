@@ -0,0 +1,164 @@
+package e365_gateway
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache(t *testing.T) {
+
+	now := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRUCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	c.Set("c", []byte("3"))
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	require.Equal(t, 2, c.Len())
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get("a")
+	require.False(t, ok)
+	c.Set("a", []byte("4"))
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "4", string(v))
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	require.False(t, ok)
+}
+
+func TestAppCache(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 5)
+	for i := 0; i < 5; i++ {
+		items = append(items, fakeItem(i, start, float64(i)))
+	}
+	fa, settings := newFakeApp(t, items)
+	fa.StatusInfo = testStatusInfo
+	cache := NewLRUCache(100, time.Minute)
+	settings.Cache = cache
+	app := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				item, err := app.GetByID(ctxBg, fakeID(0))
+				require.NoError(t, err)
+				require.Equal(t, fakeID(0), item.ID)
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 1, fa.Hits[methodGet])
+
+		_, ok := cache.Get(app.itemKey(fakeID(0), "1"))
+		require.True(t, ok)
+
+		// WithFields не использует кэш
+		_, err := app.GetByID(ctxBg, fakeID(0), WithFields("price"))
+		require.NoError(t, err)
+		require.Equal(t, 1, fa.Hits[methodGet])
+	})
+
+	t.Run("types", func(t *testing.T) {
+		fa.Items[2]["extra"] = "x"
+		_, err := app.GetByID(ctxBg, fakeID(2))
+		require.NoError(t, err)
+
+		// элемент, сохраненный в кэше в виде Product, не подходит для DynamicItem того же приложения
+		gets := fa.Hits[methodGet]
+		d, err := NewDynamicApp(settings).GetByID(ctxBg, fakeID(2))
+		require.NoError(t, err)
+		extra, ok := d.String("extra")
+		require.True(t, ok)
+		require.Equal(t, "x", extra)
+		require.Equal(t, gets+1, fa.Hits[methodGet])
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctxBg)
+		cancel()
+		_, err := app.GetByID(ctx, fakeID(3))
+		require.ErrorIs(t, err, context.Canceled)
+
+		// запрос не отменяется вместе с контекстом вызова, и его результат попадает в кэш
+		require.Eventually(t, func() bool {
+			_, ok := app.cached(fakeID(3))
+			return ok
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("get_by_ids", func(t *testing.T) {
+		lists := fa.Lists
+		found, notFound, err := app.GetByIDs(ctxBg, []string{fakeID(0), fakeID(1), fakeID(4), fakeID(99)})
+		require.NoError(t, err)
+		require.Len(t, found, 3)
+		require.Equal(t, []string{fakeID(99)}, notFound)
+		require.Equal(t, lists+1, fa.Lists)
+		require.Equal(t, []interface{}{fakeID(1), fakeID(4), fakeID(99)}, fa.Bodies[len(fa.Bodies)-1]["ids"])
+
+		_, _, err = app.GetByIDs(ctxBg, []string{fakeID(1), fakeID(4)})
+		require.NoError(t, err)
+		require.Equal(t, lists+1, fa.Lists)
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		item, err := app.GetByID(ctxBg, fakeID(1))
+		require.NoError(t, err)
+		item.Price = 100
+		_, err = app.Update(ctxBg, fakeID(1), item)
+		require.NoError(t, err)
+
+		gets := fa.Hits[methodGet]
+		item, err = app.GetByID(ctxBg, fakeID(1))
+		require.NoError(t, err)
+		require.Equal(t, 100, item.Price)
+		require.Equal(t, 2, item.Version)
+		require.Equal(t, gets, fa.Hits[methodGet])
+
+		_, err = app.SetStatus(ctxBg, fakeID(1), "closed")
+		require.NoError(t, err)
+		item, err = app.GetByID(ctxBg, fakeID(1))
+		require.NoError(t, err)
+		require.Equal(t, 3, item.Status.Status)
+		require.Equal(t, gets, fa.Hits[methodGet])
+
+		// ответ со старой версией не перезаписывает новую
+		stale := item
+		stale.Version = 1
+		stale.Price = 1
+		app.store(stale)
+		item, err = app.GetByID(ctxBg, fakeID(1))
+		require.NoError(t, err)
+		require.Equal(t, 100, item.Price)
+
+		created, err := app.Create(ctxBg, Product{Price: 7})
+		require.NoError(t, err)
+		item, err = app.GetByID(ctxBg, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, 7, item.Price)
+		require.Equal(t, gets, fa.Hits[methodGet])
+
+		// после ошибки изменения элемент удаляется из кэша
+		fa.Items = fa.Items[1:]
+		_, err = app.Update(ctxBg, fakeID(0), Product{})
+		require.Error(t, err)
+		_, err = app.GetByID(ctxBg, fakeID(0))
+		require.Error(t, err)
+		require.Equal(t, gets+1, fa.Hits[methodGet])
+	})
+}
@@ -0,0 +1,301 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+)
+
+// DynamicItem - элемент приложения без заранее известной структуры.
+// Поля хранятся в исходном json представлении в том порядке, в котором их вернул сервер (или в котором они были заданы),
+// и декодируются при обращении через String, Number, Time, Refs, Money и т.д.
+// Используется вместе с NewDynamicApp в скриптах и универсальных инструментах (выгрузка, CLI)
+type DynamicItem struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// NewDynamicApp создает адаптер к приложению с элементами DynamicItem.
+// Доступны все методы App[T]: поиск, получение, создание, обновление и т.д.
+func NewDynamicApp(settings Settings) App[DynamicItem] {
+	return NewApp[DynamicItem](settings)
+}
+
+// ToDynamic преобразует типизированный элемент (например, структуру со встроенным AppCommon) в DynamicItem
+func ToDynamic(item interface{}) (DynamicItem, error) {
+	var d DynamicItem
+	bts, err := json.Marshal(item)
+	if err != nil {
+		return d, err
+	}
+	if err = json.Unmarshal(bts, &d); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// FromDynamic преобразует DynamicItem в типизированный элемент T
+func FromDynamic[T interface{}](d DynamicItem) (T, error) {
+	var t T
+	err := d.ToStruct(&t)
+	return t, err
+}
+
+// ToStruct декодирует элемент в структуру dst (указатель), как если бы она была получена с сервера
+func (d DynamicItem) ToStruct(dst interface{}) error {
+	bts, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bts, dst)
+}
+
+// Len возвращает кол-во полей элемента
+func (d DynamicItem) Len() int {
+	return len(d.keys)
+}
+
+// Keys возвращает коды полей в исходном порядке
+func (d DynamicItem) Keys() []string {
+	return append([]string{}, d.keys...)
+}
+
+// Has сообщает, есть ли у элемента поле с кодом key (в том числе со значением null)
+func (d DynamicItem) Has(key string) bool {
+	_, ok := d.values[key]
+	return ok
+}
+
+// Raw возвращает json представление поля
+func (d DynamicItem) Raw(key string) (json.RawMessage, bool) {
+	raw, ok := d.values[key]
+	return raw, ok
+}
+
+// Get возвращает значение поля, декодированное в стандартные типы encoding/json (числа - json.Number)
+func (d DynamicItem) Get(key string) (interface{}, bool) {
+	raw, ok := d.values[key]
+	if !ok {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// Decode декодирует значение поля в dst (указатель), например, в types.Table или структуру
+func (d DynamicItem) Decode(key string, dst interface{}) error {
+	raw, ok := d.values[key]
+	if !ok {
+		return wrap(key, ErrUnknownField)
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return &FieldError{Item: "DynamicItem", Field: key, Reason: err.Error(), Err: ErrFieldType}
+	}
+	return nil
+}
+
+// Set задает значение поля. Новые поля добавляются в конец
+func (d *DynamicItem) Set(key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return &FieldError{Item: "DynamicItem", Field: key, Reason: err.Error(), Err: ErrFieldType}
+	}
+	d.setRaw(key, raw)
+	return nil
+}
+
+// Delete удаляет поле
+func (d *DynamicItem) Delete(key string) {
+	if _, ok := d.values[key]; !ok {
+		return
+	}
+	delete(d.values, key)
+	keys := make([]string, 0, len(d.keys)-1)
+	for _, k := range d.keys {
+		if k != key {
+			keys = append(keys, k)
+		}
+	}
+	d.keys = keys
+}
+
+func (d *DynamicItem) setRaw(key string, raw json.RawMessage) {
+	if d.values == nil {
+		d.values = make(map[string]json.RawMessage)
+	}
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = raw
+}
+
+// decodeDynamic декодирует непустое значение поля в V
+func decodeDynamic[V interface{}](d DynamicItem, key string) (V, bool) {
+	var v V
+	raw, ok := d.values[key]
+	if !ok || string(bytes.TrimSpace(raw)) == "null" {
+		return v, false
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// String возвращает значение строкового поля
+func (d DynamicItem) String(key string) (string, bool) {
+	return decodeDynamic[string](d, key)
+}
+
+// Number возвращает значение числового поля
+func (d DynamicItem) Number(key string) (float64, bool) {
+	return decodeDynamic[float64](d, key)
+}
+
+// Int возвращает значение целочисленного поля
+func (d DynamicItem) Int(key string) (int64, bool) {
+	return decodeDynamic[int64](d, key)
+}
+
+// Bool возвращает значение поля типа "Да/Нет"
+func (d DynamicItem) Bool(key string) (bool, bool) {
+	return decodeDynamic[bool](d, key)
+}
+
+// Time возвращает значение поля типа "Дата/время"
+func (d DynamicItem) Time(key string) (time.Time, bool) {
+	return decodeDynamic[time.Time](d, key)
+}
+
+// Date возвращает значение поля типа "Дата" (без времени)
+func (d DynamicItem) Date(key string) (types.Date, bool) {
+	return decodeDynamic[types.Date](d, key)
+}
+
+// Refs возвращает id элементов, на которые ссылается поле типа "Приложение" (одиночное или множественное)
+func (d DynamicItem) Refs(key string) ([]string, bool) {
+	raw, ok := d.values[key]
+	if !ok {
+		return nil, false
+	}
+	ids, err := unmarshalRefIDs(raw)
+	if err != nil || ids == nil {
+		return nil, false
+	}
+	return ids, true
+}
+
+// Users возвращает id пользователей из поля типа "Пользователи"
+func (d DynamicItem) Users(key string) (types.UserRefs, bool) {
+	return decodeDynamic[types.UserRefs](d, key)
+}
+
+// Money возвращает значение поля типа "Деньги"
+func (d DynamicItem) Money(key string) (types.Money, bool) {
+	return decodeDynamic[types.Money](d, key)
+}
+
+// Category возвращает значение одиночного поля типа "Категория"
+func (d DynamicItem) Category(key string) (types.Category, bool) {
+	return decodeDynamic[types.Category](d, key)
+}
+
+// Categories возвращает значения множественного поля типа "Категория"
+func (d DynamicItem) Categories(key string) (types.Categories, bool) {
+	return decodeDynamic[types.Categories](d, key)
+}
+
+// Phones возвращает значение поля типа "Телефон"
+func (d DynamicItem) Phones(key string) (types.Phones, bool) {
+	return decodeDynamic[types.Phones](d, key)
+}
+
+// Emails возвращает значение поля типа "Email"
+func (d DynamicItem) Emails(key string) (types.Emails, bool) {
+	return decodeDynamic[types.Emails](d, key)
+}
+
+// FullName возвращает значение поля типа "ФИО"
+func (d DynamicItem) FullName(key string) (types.FullName, bool) {
+	return decodeDynamic[types.FullName](d, key)
+}
+
+// Common возвращает служебные поля элемента (__id, __name, __createdAt и т.д.)
+func (d DynamicItem) Common() AppCommon {
+	service := DynamicItem{}
+	for _, k := range d.keys {
+		if strings.HasPrefix(k, "__") {
+			service.setRaw(k, d.values[k])
+		}
+	}
+	var c AppCommon
+	_ = service.ToStruct(&c)
+	return c
+}
+
+func (d DynamicItem) appCommon() AppCommon {
+	return d.Common()
+}
+
+func (d DynamicItem) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 64*len(d.keys)))
+	buf.WriteByte('{')
+	for i, k := range d.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(d.values[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (d *DynamicItem) UnmarshalJSON(bts []byte) error {
+	*d = DynamicItem{}
+	if string(bytes.TrimSpace(bts)) == "null" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bts))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("cannot unmarshal %v into DynamicItem", tok)
+	}
+
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected object key %v", tok)
+		}
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return err
+		}
+		d.setRaw(key, raw)
+	}
+
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,127 @@
+package e365_gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicItem(t *testing.T) {
+
+	t.Run("accessors", func(t *testing.T) {
+		src := `{
+			"__id": "` + fakeID(1) + `",
+			"title": "box",
+			"price": 12.5,
+			"qty": 3,
+			"paid": true,
+			"deadline": "2023-08-01T10:00:00Z",
+			"day": "2023-08-02",
+			"order": ["` + fakeID(2) + `"],
+			"cost": {"cents": 1250, "currency": "RUB"},
+			"kind": [{"code": "big", "name": "Big"}],
+			"empty": null
+		}`
+
+		var d DynamicItem
+		require.NoError(t, json.Unmarshal([]byte(src), &d))
+		require.Equal(t, []string{"__id", "title", "price", "qty", "paid", "deadline", "day", "order", "cost", "kind", "empty"}, d.Keys())
+
+		title, ok := d.String("title")
+		require.True(t, ok)
+		require.Equal(t, "box", title)
+		_, ok = d.String("price")
+		require.False(t, ok)
+
+		price, _ := d.Number("price")
+		require.Equal(t, 12.5, price)
+		qty, _ := d.Int("qty")
+		require.Equal(t, int64(3), qty)
+		paid, _ := d.Bool("paid")
+		require.True(t, paid)
+		deadline, _ := d.Time("deadline")
+		require.Equal(t, time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC), deadline)
+		day, _ := d.Date("day")
+		require.Equal(t, "2023-08-02", day.String())
+		refs, _ := d.Refs("order")
+		require.Equal(t, []string{fakeID(2)}, refs)
+		cost, _ := d.Money("cost")
+		require.Equal(t, types.NewMoney(12.5, "RUB"), cost)
+		kind, _ := d.Category("kind")
+		require.Equal(t, "big", kind.Code)
+
+		require.True(t, d.Has("empty"))
+		_, ok = d.String("empty")
+		require.False(t, ok)
+		_, ok = d.Refs("missing")
+		require.False(t, ok)
+		require.Equal(t, fakeID(1), d.Common().ID)
+
+		require.NoError(t, d.Set("title", "crate"))
+		require.NoError(t, d.Set("extra", 1))
+		d.Delete("empty")
+		bts, err := json.Marshal(d)
+		require.NoError(t, err)
+		require.Contains(t, string(bts), `"title":"crate"`)
+		require.Equal(t, "extra", d.Keys()[d.Len()-1])
+		require.NotContains(t, string(bts), "empty")
+	})
+
+	t.Run("struct_conversion", func(t *testing.T) {
+		p := Product{AppCommon: AppCommon{ID: fakeID(5), Name: "five"}, Price: 50}
+		d, err := ToDynamic(p)
+		require.NoError(t, err)
+		name, _ := d.String("__name")
+		require.Equal(t, "five", name)
+
+		require.NoError(t, d.Set("price", 60))
+		back, err := FromDynamic[Product](d)
+		require.NoError(t, err)
+		require.Equal(t, fakeID(5), back.ID)
+		require.Equal(t, 60, back.Price)
+
+		var zero DynamicItem
+		bts, err := json.Marshal(zero)
+		require.NoError(t, err)
+		require.Equal(t, "{}", string(bts))
+	})
+
+	t.Run("app", func(t *testing.T) {
+		start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+		items := make([]map[string]interface{}, 0, 30)
+		for i := 0; i < 30; i++ {
+			items = append(items, fakeItem(i, start.Add(time.Duration(i)*time.Hour), float64(i)))
+		}
+		_, settings := newFakeApp(t, items)
+		app := NewDynamicApp(settings)
+		ctxBg := context.Background()
+
+		found, err := app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().From(20)}}).OrderBy("price").Strict().Size(100).All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, found, 10)
+		price, _ := found[0].Number("price")
+		require.Equal(t, 20.0, price)
+
+		count := 0
+		err = app.Search().Size(7).Iter().Each(ctxBg, func(items []DynamicItem) error {
+			count += len(items)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 30, count)
+
+		item := found[0]
+		require.NoError(t, item.Set("price", 1000))
+		updated, err := app.Update(ctxBg, item.Common().ID, item)
+		require.NoError(t, err)
+		price, _ = updated.Number("price")
+		require.Equal(t, 1000.0, price)
+		version, _ := updated.Int("__version")
+		require.Equal(t, int64(2), version)
+	})
+
+}
@@ -0,0 +1,331 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+)
+
+// ExportFormat - формат выгрузки
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+// ExportOptions - параметры выгрузки
+type ExportOptions struct {
+	// Columns - коды полей в порядке колонок. По умолчанию все поля T (для DynamicItem - поля первого элемента)
+	Columns []string
+	// Headers - заголовки колонок CSV по коду поля (по умолчанию заголовок - код поля)
+	Headers map[string]string
+	// DateFormat - формат дат (по умолчанию time.RFC3339)
+	DateFormat string
+	// Location - часовой пояс дат (по умолчанию UTC)
+	Location *time.Location
+	// Separator - разделитель значений множественных полей (по умолчанию ", ")
+	Separator string
+	// Comma - разделитель колонок CSV (по умолчанию ',')
+	Comma rune
+	// MoneyCurrency - выгружать деньги вместе с кодом валюты ("12.50 RUB"), а не только суммой ("12.50")
+	MoneyCurrency bool
+	// StatusName - выгружать название статуса, а не его код
+	StatusName bool
+	// Flatten - в JSONL выгружать значения так же, как в CSV (строками), а не в исходном json представлении
+	Flatten bool
+}
+
+// Export выгружает все найденные элементы в w в формате CSV или JSONL и возвращает кол-во выгруженных элементов.
+// Элементы получаются через ScanEach (по возрастанию __createdAt, без ограничения сервера на From) и не накапливаются
+// в памяти. Если колонки заданы, у сервера запрашиваются только они.
+//
+// В CSV составные значения приводятся к строкам: деньги - к сумме, ФИО - к "Фамилия Имя Отчество",
+// категории - к кодам вариантов, ссылки на элементы и пользователей - к id, телефоны и email - к номерам и адресам
+// (несколько значений объединяются через Separator), статус - к коду (или названию), даты - к DateFormat в Location.
+// Датами считаются поля, у которых в T тип time.Time (для DynamicItem - поля типа "Дата/время" в схеме приложения).
+// Остальные объекты выгружаются как json
+func (s searchInstance[T]) Export(ctx context.Context, w io.Writer, format ExportFormat, opts ExportOptions) (int, error) {
+
+	if format != ExportCSV && format != ExportJSONL {
+		return 0, wrap(string(format), ErrUnsupportedFormat)
+	}
+
+	ex := exporter{opts: opts, columns: opts.Columns, times: make(map[string]bool)}
+	if ex.opts.DateFormat == "" {
+		ex.opts.DateFormat = time.RFC3339
+	}
+	if ex.opts.Location == nil {
+		ex.opts.Location = time.UTC
+	}
+	if ex.opts.Separator == "" {
+		ex.opts.Separator = ", "
+	}
+	for _, code := range []string{fieldCreatedAt, fieldUpdatedAt, fieldDeletedAt} {
+		ex.times[code] = true
+	}
+	fields := itemFieldsOf(reflect.TypeOf((*T)(nil)).Elem())
+	for _, f := range fields {
+		t := f.typ
+		if t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if fieldKindOf(t) == kindTime {
+			ex.times[f.code] = true
+		}
+	}
+	if _, dynamic := interface{}((*T)(nil)).(*DynamicItem); dynamic {
+		schema, err := s.app.Schema(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, f := range schema.Fields {
+			if f.Type == FieldTypeDateTime {
+				ex.times[f.Code] = true
+			}
+		}
+	}
+	if len(ex.columns) == 0 {
+		for _, f := range fields {
+			ex.columns = append(ex.columns, f.code)
+		}
+	} else {
+		s.selected = nil
+		s = s.Select(ex.columns...)
+	}
+
+	var cw *csv.Writer
+	if format == ExportCSV {
+		cw = csv.NewWriter(w)
+		if opts.Comma != 0 {
+			cw.Comma = opts.Comma
+		}
+	}
+
+	count := 0
+	err := s.ScanEach(ctx, ScanOptions{}, func(items []T) error {
+		for _, item := range items {
+			d, err := ToDynamic(item)
+			if err != nil {
+				return wrap(err.Error(), ErrExportItem)
+			}
+			if len(ex.columns) == 0 {
+				ex.columns = d.Keys()
+			}
+			if ex.status == nil && ex.hasColumn(fieldStatus) {
+				if ex.status, err = s.statusNames(ctx, opts.StatusName); err != nil {
+					return err
+				}
+			}
+
+			if cw != nil {
+				if count == 0 {
+					if err = cw.Write(ex.header()); err != nil {
+						return err
+					}
+				}
+				if err = cw.Write(ex.row(d)); err != nil {
+					return err
+				}
+			} else if err = ex.writeJSON(w, d); err != nil {
+				return err
+			}
+			count++
+		}
+		if cw != nil {
+			cw.Flush()
+			return cw.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	// у пустой выгрузки CSV остается только заголовок
+	if cw != nil && count == 0 && len(ex.columns) > 0 {
+		if err = cw.Write(ex.header()); err != nil {
+			return 0, err
+		}
+		cw.Flush()
+		return 0, cw.Error()
+	}
+	return count, nil
+}
+
+// statusNames возвращает коды (или названия) статусов по id
+func (s searchInstance[T]) statusNames(ctx context.Context, byName bool) (map[int]string, error) {
+	info, err := s.app.GetStatusInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(info.StatusItems))
+	for _, st := range info.StatusItems {
+		names[st.Id] = st.Code
+		if byName {
+			names[st.Id] = st.Name
+		}
+	}
+	return names, nil
+}
+
+// exporter приводит элементы к строкам выгрузки
+type exporter struct {
+	opts    ExportOptions
+	columns []string
+	status  map[int]string
+	// times - коды полей, значения которых приводятся к DateFormat
+	times map[string]bool
+}
+
+func (ex exporter) hasColumn(code string) bool {
+	for _, c := range ex.columns {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (ex exporter) header() []string {
+	header := make([]string, 0, len(ex.columns))
+	for _, c := range ex.columns {
+		if h, ok := ex.opts.Headers[c]; ok {
+			c = h
+		}
+		header = append(header, c)
+	}
+	return header
+}
+
+func (ex exporter) row(d DynamicItem) []string {
+	row := make([]string, 0, len(ex.columns))
+	for _, c := range ex.columns {
+		raw, _ := d.Raw(c)
+		row = append(row, ex.flatten(c, raw))
+	}
+	return row
+}
+
+func (ex exporter) writeJSON(w io.Writer, d DynamicItem) error {
+	var out DynamicItem
+	for _, c := range ex.columns {
+		raw, ok := d.Raw(c)
+		if !ok {
+			raw = json.RawMessage("null")
+		}
+		if ex.opts.Flatten {
+			if err := out.Set(c, ex.flatten(c, raw)); err != nil {
+				return err
+			}
+			continue
+		}
+		out.setRaw(c, raw)
+	}
+	bts, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bts, '\n'))
+	return err
+}
+
+// flatten приводит значение поля к строке
+func (ex exporter) flatten(code string, raw json.RawMessage) string {
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return string(raw)
+	}
+
+	switch val := v.(type) {
+	case string:
+		if !ex.times[code] {
+			return val
+		}
+		if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			if t.IsZero() {
+				return ""
+			}
+			return t.In(ex.opts.Location).Format(ex.opts.DateFormat)
+		}
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	case map[string]interface{}:
+		return ex.flattenObject(code, raw, val)
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+		for _, elem := range val {
+			bts, err := json.Marshal(elem)
+			if err != nil {
+				return string(raw)
+			}
+			if p := ex.flatten(code, bts); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		return strings.Join(parts, ex.opts.Separator)
+	}
+	return string(raw)
+}
+
+func (ex exporter) flattenObject(code string, raw json.RawMessage, obj map[string]interface{}) string {
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if _, ok := obj[k]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case code == fieldStatus && has("status"):
+		var st Status
+		if err := json.Unmarshal(raw, &st); err == nil {
+			if name, ok := ex.status[st.Status]; ok {
+				return name
+			}
+			return strconv.Itoa(st.Status)
+		}
+	case has("cents", "currency"):
+		var m types.Money
+		if err := json.Unmarshal(raw, &m); err == nil {
+			if ex.opts.MoneyCurrency {
+				return m.String()
+			}
+			return types.Money{Cents: m.Cents}.String()
+		}
+	case has("firstname") || has("lastname"):
+		var fn types.FullName
+		if err := json.Unmarshal(raw, &fn); err == nil {
+			return fn.String()
+		}
+	case has("code"):
+		return fmt.Sprint(obj["code"])
+	case has("tel"):
+		return fmt.Sprint(obj["tel"])
+	case has("email"):
+		return fmt.Sprint(obj["email"])
+	}
+	return string(raw)
+}
@@ -0,0 +1,144 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+type exportItem struct {
+	AppCommon
+	Price  int              `json:"price"`
+	Cost   types.Money      `json:"cost"`
+	Owner  types.FullName   `json:"owner"`
+	Kind   types.Categories `json:"kind"`
+	Parent AppRefs[Product] `json:"parent"`
+	Note   string           `json:"note"`
+	Due    time.Time        `json:"due"`
+}
+
+func TestExport(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 21, 30, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		item := fakeItem(i, start.Add(time.Duration(i)*time.Minute), float64(i))
+		item["__status"] = map[string]interface{}{"order": float64(0), "status": float64(1 + i%3)}
+		item["cost"] = map[string]interface{}{"cents": float64(i*100 + 5), "currency": "RUB"}
+		item["owner"] = map[string]interface{}{"firstname": "Иван", "lastname": "Петров", "middlename": ""}
+		item["kind"] = []interface{}{map[string]interface{}{"code": "a", "name": "А"}, map[string]interface{}{"code": "b", "name": "Б"}}
+		item["parent"] = []interface{}{fakeID(1000), fakeID(1001)}
+		item["note"] = "2023-08-01T10:00:00+03:00"
+		item["due"] = "2023-08-01T10:00:00+03:00"
+		items = append(items, item)
+	}
+	fa, settings := newFakeApp(t, items)
+	fa.StatusInfo = testStatusInfo
+	app := NewApp[exportItem](settings)
+	ctxBg := context.Background()
+
+	t.Run("csv", func(t *testing.T) {
+		msk := time.FixedZone("MSK", 3*60*60)
+		buf := &bytes.Buffer{}
+		n, err := app.Search().Export(ctxBg, buf, ExportCSV, ExportOptions{
+			Columns:    []string{"__id", "__status", "price", "cost", "owner", "kind", "parent", "__createdAt"},
+			Headers:    map[string]string{"__id": "id", "__createdAt": "created"},
+			DateFormat: "02.01.2006 15:04",
+			Location:   msk,
+			Separator:  ";",
+		})
+		require.NoError(t, err)
+		require.Equal(t, 150, n)
+		require.Equal(t, map[string]interface{}{"*": false, "__id": true, "__status": true, "price": true, "cost": true,
+			"owner": true, "kind": true, "parent": true, "__createdAt": true}, fa.Bodies[len(fa.Bodies)-1]["fields"])
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 151)
+		require.Equal(t, []string{"id", "__status", "price", "cost", "owner", "kind", "parent", "created"}, rows[0])
+		require.Equal(t, []string{fakeID(1), "in_work", "1", "1.05", "Петров Иван", "a;b",
+			fakeID(1000) + ";" + fakeID(1001), "02.08.2023 00:31"}, rows[2])
+	})
+
+	t.Run("csv_default_columns", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(0)}}).
+			Export(ctxBg, buf, ExportCSV, ExportOptions{Comma: ';', StatusName: true, MoneyCurrency: true})
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		r := csv.NewReader(buf)
+		r.Comma = ';'
+		rows, err := r.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		row := make(map[string]string)
+		for i, h := range rows[0] {
+			row[h] = rows[1][i]
+		}
+		require.Equal(t, "Новый", row["__status"])
+		require.Equal(t, "0.05 RUB", row["cost"])
+		require.Equal(t, "2023-08-01T21:30:00Z", row["__createdAt"])
+		require.Equal(t, "", row["__deletedAt"])
+		// строка, похожая на дату, выгружается как есть, а поле типа time.Time - в DateFormat и Location
+		require.Equal(t, "2023-08-01T10:00:00+03:00", row["note"])
+		require.Equal(t, "2023-08-01T07:00:00Z", row["due"])
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(1)}}).
+			Export(ctxBg, buf, ExportJSONL, ExportOptions{Columns: []string{"price", "cost", "__status"}})
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Equal(t, `{"price":0,"cost":{"cents":5,"currency":"RUB"},"__status":{"status":1}}`, lines[0])
+
+		buf.Reset()
+		_, err = app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(1)}}).
+			Export(ctxBg, buf, ExportJSONL, ExportOptions{Columns: []string{"price", "cost", "__status"}, Flatten: true})
+		require.NoError(t, err)
+		lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Equal(t, `{"price":"1","cost":"1.05","__status":"in_work"}`, lines[1])
+	})
+
+	t.Run("dynamic", func(t *testing.T) {
+		fa.Fields = []map[string]interface{}{{"code": "note", "type": "STRING"}, {"code": "due", "type": "DATETIME"}}
+		t.Cleanup(func() { fa.Fields = nil })
+		buf := &bytes.Buffer{}
+		n, err := NewDynamicApp(settings).Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(0)}}).
+			Export(ctxBg, buf, ExportCSV, ExportOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		rows, err := csv.NewReader(buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		require.Contains(t, rows[0], "owner")
+
+		buf.Reset()
+		_, err = NewDynamicApp(settings).Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(0)}}).
+			Export(ctxBg, buf, ExportCSV, ExportOptions{Columns: []string{"owner", "note", "due"}})
+		require.NoError(t, err)
+		rows, err = csv.NewReader(buf).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, [][]string{{"owner", "note", "due"}, {"Петров Иван", "2023-08-01T10:00:00+03:00", "2023-08-01T07:00:00Z"}}, rows)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		n, err := app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().From(1000)}}).
+			Export(ctxBg, buf, ExportCSV, ExportOptions{Columns: []string{"__id", "price"}})
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+		require.Equal(t, "__id,price\n", buf.String())
+
+		_, err = app.Search().Export(ctxBg, buf, "xlsx", ExportOptions{})
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
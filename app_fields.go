@@ -0,0 +1,357 @@
+package e365_gateway
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+)
+
+// itemField - поле структуры элемента приложения, доступное через json
+type itemField struct {
+	code  string
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// itemFieldsCache кэширует поля структур: reflect.Type -> []itemField
+var itemFieldsCache sync.Map
+
+// itemFieldsOf возвращает поля структуры t так, как их видит encoding/json
+// (с учетом встроенных структур, например AppCommon)
+func itemFieldsOf(t reflect.Type) []itemField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if cached, ok := itemFieldsCache.Load(t); ok {
+		return cached.([]itemField)
+	}
+
+	fields := make([]itemField, 0)
+	if w, ok := reflect.Zero(t).Interface().(interface{ rawItem() reflect.Type }); ok {
+		// WithRaw[T]: поля T доступны через WithRaw.Item
+		for _, f := range itemFieldsOf(w.rawItem()) {
+			f.index = append([]int{0}, f.index...)
+			fields = append(fields, f)
+		}
+	} else if t.Kind() == reflect.Struct {
+		fields = collectItemFields(t, nil, map[string]struct{}{})
+	}
+	itemFieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectItemFields(t reflect.Type, index []int, seen map[string]struct{}) []itemField {
+
+	fields := make([]itemField, 0, t.NumField())
+	embedded := make([]reflect.StructField, 0)
+
+	// поля верхнего уровня перекрывают поля встроенных структур
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		code, _, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && code == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, sf)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if code == "" {
+			code = sf.Name
+		}
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		fields = append(fields, itemField{
+			code:  code,
+			name:  sf.Name,
+			index: append(append([]int{}, index...), i),
+			typ:   sf.Type,
+		})
+	}
+
+	for _, sf := range embedded {
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fields = append(fields, collectItemFields(ft, append(append([]int{}, index...), sf.Index...), seen)...)
+	}
+
+	return fields
+}
+
+// lookupItemField ищет поле по коду (json тегу) или по имени поля в Go
+func lookupItemField(t reflect.Type, name string) (itemField, bool) {
+	fields := itemFieldsOf(t)
+	for _, f := range fields {
+		if f.code == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return itemField{}, false
+}
+
+// FieldError - ошибка построения фильтра или сортировки по полю элемента приложения
+type FieldError struct {
+	Item   string
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s: %s.%s", e.Err, e.Item, e.Field)
+	}
+	return fmt.Sprintf("%s: %s.%s (%s)", e.Err, e.Item, e.Field, e.Reason)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldRef - ссылка на поле элемента приложения, проверенная по структуре T
+type FieldRef struct {
+	item  reflect.Type
+	field itemField
+}
+
+// FieldOf возвращает ссылку на поле структуры T по имени поля в Go ("Price") или по его коду ("price").
+// Если поля нет, возвращается *FieldError с ErrUnknownField.
+func FieldOf[T interface{}](name string) (FieldRef, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	f, ok := lookupItemField(t, name)
+	if !ok {
+		return FieldRef{}, &FieldError{Item: t.String(), Field: name, Err: ErrUnknownField}
+	}
+	return FieldRef{item: t, field: f}, nil
+}
+
+// MustFieldOf работает как FieldOf, но паникует, если поля нет.
+// Удобно для объявления ссылок на уровне пакета: ошибка проявится при старте программы.
+func MustFieldOf[T interface{}](name string) FieldRef {
+	ref, err := FieldOf[T](name)
+	if err != nil {
+		panic(err)
+	}
+	return ref
+}
+
+// Code возвращает код поля, используемый в фильтрах и сортировке
+func (ref FieldRef) Code() string {
+	return ref.field.code
+}
+
+// Filter проверяет, что значение фильтра подходит к типу поля, и возвращает фильтр по этому полю
+func (ref FieldRef) Filter(value interface{}) (Fields, error) {
+	if err := checkFieldValue(ref.item, ref.field, value); err != nil {
+		return nil, err
+	}
+	return Fields{ref.field.code: value}, nil
+}
+
+// Asc возвращает сортировку по возрастанию значения поля
+func (ref FieldRef) Asc() SortExpression {
+	return SortExpression{Ascending: true, Field: ref.field.code}
+}
+
+// Desc возвращает сортировку по убыванию значения поля
+func (ref FieldRef) Desc() SortExpression {
+	return SortExpression{Ascending: false, Field: ref.field.code}
+}
+
+// FieldsBuilder собирает Fields с проверкой полей по структуре T.
+// Первая найденная ошибка возвращается из Build.
+type FieldsBuilder[T interface{}] struct {
+	fields Fields
+	err    error
+}
+
+// FieldsFor создает конструктор фильтра по полям структуры T
+func FieldsFor[T interface{}]() FieldsBuilder[T] {
+	return FieldsBuilder[T]{fields: Fields{}}
+}
+
+// Set добавляет фильтр по полю (имя поля в Go или его код)
+func (fb FieldsBuilder[T]) Set(name string, value interface{}) FieldsBuilder[T] {
+	if fb.err != nil {
+		return fb
+	}
+	ref, err := FieldOf[T](name)
+	if err != nil {
+		fb.err = err
+		return fb
+	}
+	f, err := ref.Filter(value)
+	if err != nil {
+		fb.err = err
+		return fb
+	}
+	fields := make(Fields, len(fb.fields)+1)
+	for k, v := range fb.fields {
+		fields[k] = v
+	}
+	for k, v := range f {
+		fields[k] = v
+	}
+	fb.fields = fields
+	return fb
+}
+
+// Build возвращает собранный фильтр или первую ошибку
+func (fb FieldsBuilder[T]) Build() (Fields, error) {
+	if fb.err != nil {
+		return nil, fb.err
+	}
+	return fb.fields, nil
+}
+
+// ValidateFilter проверяет, что все поля фильтра, условия и сортировки есть в структуре T,
+// а значения Fields подходят к типам полей
+func ValidateFilter[T interface{}](sf SearchFilter) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	for code, value := range sf.Fields {
+		f, ok := lookupItemField(t, code)
+		if !ok || f.code != code {
+			return &FieldError{Item: t.String(), Field: code, Err: ErrUnknownField}
+		}
+		if err := checkFieldValue(t, f, value); err != nil {
+			return err
+		}
+	}
+
+	codes := make([]string, 0, len(sf.SortExpressions))
+	for _, se := range sf.SortExpressions {
+		codes = append(codes, se.Field)
+	}
+	if sf.Query != nil {
+		codes = append(codes, sf.Query.fields()...)
+	}
+	for _, code := range codes {
+		if f, ok := lookupItemField(t, code); !ok || f.code != code {
+			return &FieldError{Item: t.String(), Field: code, Err: ErrUnknownField}
+		}
+	}
+
+	return nil
+}
+
+// Strict включает проверку фильтра по структуре T (см. ValidateFilter) перед выполнением поиска
+func (s searchInstance[T]) Strict() searchInstance[T] {
+	s.strict = true
+	return s
+}
+
+// check проверяет параметры поиска перед выполнением запроса
+func (s searchInstance[T]) check() error {
+	if !s.strict {
+		return nil
+	}
+	// у DynamicItem нет заранее известных полей, проверять не по чему
+	if _, ok := interface{}((*T)(nil)).(*DynamicItem); ok {
+		return nil
+	}
+	return ValidateFilter[T](s.search)
+}
+
+type fieldKind int
+
+const (
+	kindOther fieldKind = iota
+	kindNumber
+	kindTime
+	kindBool
+	kindString
+	kindList
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	moneyType = reflect.TypeOf(types.Money{})
+	dateType  = reflect.TypeOf(types.Date{})
+)
+
+// fieldKindOf определяет, какие фильтры подходят к полю типа t
+func fieldKindOf(t reflect.Type) fieldKind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType, dateType:
+		return kindTime
+	case moneyType:
+		return kindNumber
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return kindNumber
+	case reflect.Bool:
+		return kindBool
+	case reflect.String:
+		return kindString
+	case reflect.Slice, reflect.Array:
+		return kindList
+	}
+	return kindOther
+}
+
+// checkFieldValue проверяет, что значение фильтра подходит к типу поля
+func checkFieldValue(item reflect.Type, f itemField, value interface{}) error {
+
+	kind := fieldKindOf(f.typ)
+	if kind == kindOther || value == nil {
+		return nil
+	}
+
+	var allowed []fieldKind
+	switch value.(type) {
+	case appNumberFilter, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		allowed = []fieldKind{kindNumber}
+	case appDateFilter:
+		allowed = []fieldKind{kindTime}
+	case bool:
+		allowed = []fieldKind{kindBool}
+	case string:
+		allowed = []fieldKind{kindString, kindList}
+	case [1]string, []string:
+		allowed = []fieldKind{kindList}
+	default:
+		return nil
+	}
+
+	for _, k := range allowed {
+		if k == kind {
+			return nil
+		}
+	}
+
+	return &FieldError{
+		Item:   item.String(),
+		Field:  f.code,
+		Reason: fmt.Sprintf("%T is not applicable to %s", value, f.typ),
+		Err:    ErrFieldType,
+	}
+}
@@ -0,0 +1,116 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldRefs(t *testing.T) {
+
+	type Order struct {
+		AppCommon
+		Price    float64     `json:"price"`
+		Paid     bool        `json:"paid"`
+		Customer []string    `json:"customer"`
+		Comment  string      `json:"comment,omitempty"`
+		Deadline time.Time   `json:"deadline"`
+		Hidden   string      `json:"-"`
+		Budget   types.Money `json:"budget"`
+		Birthday types.Date  `json:"birthday"`
+	}
+
+	t.Run("field_of", func(t *testing.T) {
+		testCases := []struct {
+			name         string
+			field        string
+			expectedCode string
+			expectedErr  error
+		}{
+			{name: "by_go_name", field: "Price", expectedCode: "price"},
+			{name: "by_code", field: "price", expectedCode: "price"},
+			{name: "embedded", field: "CreatedAt", expectedCode: "__createdAt"},
+			{name: "typo", field: "prcie", expectedErr: ErrUnknownField},
+			{name: "skipped", field: "Hidden", expectedErr: ErrUnknownField},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				ref, err := FieldOf[Order](tc.field)
+				require.ErrorIs(t, err, tc.expectedErr)
+				if tc.expectedErr != nil {
+					var fe *FieldError
+					require.ErrorAs(t, err, &fe)
+					require.Equal(t, tc.field, fe.Field)
+					return
+				}
+				require.Equal(t, tc.expectedCode, ref.Code())
+			})
+		}
+
+		require.Panics(t, func() { MustFieldOf[Order]("prcie") })
+		require.Equal(t, SortExpression{Field: "__id", Ascending: true}, MustFieldOf[Order]("ID").Asc())
+	})
+
+	t.Run("value_types", func(t *testing.T) {
+		testCases := []struct {
+			name        string
+			field       string
+			value       interface{}
+			expectedErr error
+		}{
+			{name: "number_range", field: "Price", value: Field.Number().From(10)},
+			{name: "date_range_on_number", field: "Price", value: Field.DateTime(), expectedErr: ErrFieldType},
+			{name: "date_range", field: "Deadline", value: Field.DateTime().From(time.Now())},
+			{name: "number_range_on_date", field: "Deadline", value: Field.Number(), expectedErr: ErrFieldType},
+			{name: "bool", field: "Paid", value: true},
+			{name: "string_on_bool", field: "Paid", value: "true", expectedErr: ErrFieldType},
+			{name: "app", field: "Customer", value: Field.App("72f60b1a-168e-412f-8cfd-e119c28e99b7")},
+			{name: "app_on_string", field: "Comment", value: Field.App("72f60b1a-168e-412f-8cfd-e119c28e99b7"), expectedErr: ErrFieldType},
+			{name: "string", field: "Comment", value: "text"},
+			{name: "money_range", field: "Budget", value: Field.Number().From(100)},
+			{name: "date_only_range", field: "Birthday", value: Field.DateTime().EqualDate(time.Now())},
+			{name: "bool_on_money", field: "Budget", value: true, expectedErr: ErrFieldType},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				fields, err := FieldsFor[Order]().Set(tc.field, tc.value).Build()
+				require.ErrorIs(t, err, tc.expectedErr)
+				if tc.expectedErr != nil {
+					return
+				}
+				require.Len(t, fields, 1)
+			})
+		}
+	})
+
+	t.Run("strict_search", func(t *testing.T) {
+		orders := NewApp[Order](Settings{})
+		ctxBg := context.Background()
+
+		_, err := orders.Search().Strict().Where(SearchFilter{
+			Fields: Fields{"prcie": Field.Number().From(1)},
+		}).All(ctxBg)
+		require.ErrorIs(t, err, ErrUnknownField)
+
+		_, err = orders.Search().Strict().Where(SearchFilter{
+			SortExpressions: []SortExpression{{Field: "Price"}},
+		}).Count(ctxBg)
+		require.ErrorIs(t, err, ErrUnknownField)
+
+		_, err = orders.Search().Strict().Match(Or(Eq("paid", true), IsNull("comentt"))).First(ctxBg)
+		require.ErrorIs(t, err, ErrUnknownField)
+
+		err = ValidateFilter[Order](SearchFilter{
+			Fields:          Fields{"deadline": Field.DateTime(), "__status": nil},
+			SortExpressions: []SortExpression{{Field: "price"}},
+			Query:           Contains("comment", "x"),
+		})
+		require.NoError(t, err)
+	})
+
+}
@@ -0,0 +1,105 @@
+package e365_gateway
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	idsChunkSize      = 100
+	idsGoroutineLimit = 4
+)
+
+// GetByIDs получает элементы приложения по списку id.
+// Повторяющиеся id запрашиваются один раз, запросы на /list выполняются параллельно пачками по 100 id.
+// Элементы возвращаются в порядке переданных id, а id, для которых элементы не найдены, - отдельным списком.
+// Если задан Settings.Cache, с сервера запрашиваются только элементы, которых нет в кэше
+func (app App[T]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if len(id) != uuid4Len {
+			return nil, nil, wrap(id, ErrInvalidID)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	found := make(map[string]T, len(unique))
+	missing := unique
+	if app.cache != nil {
+		missing = make([]string, 0, len(unique))
+		for _, id := range unique {
+			if item, ok := app.cached(id); ok {
+				found[id] = item
+				continue
+			}
+			missing = append(missing, id)
+		}
+	}
+
+	fetched, err := app.fetchByIDs(ctx, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	for id, item := range fetched {
+		app.store(item)
+		found[id] = item
+	}
+
+	items := make([]T, 0, len(found))
+	notFound := make([]string, 0)
+	for _, id := range unique {
+		item, ok := found[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	return items, notFound, nil
+}
+
+// fetchByIDs получает элементы по id (включая удаленные) через SearchFilter.IDs, параллельно пачками по 100
+func (app App[T]) fetchByIDs(ctx context.Context, ids []string) (map[string]T, error) {
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(idsGoroutineLimit)
+
+	found := make(map[string]T, len(ids))
+	mu := sync.Mutex{}
+	for start := 0; start < len(ids); start += idsChunkSize {
+		end := start + idsChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+		eg.Go(func() error {
+			items, _, err := app.find(egCtx, filter{
+				Size:         len(chunk),
+				SearchFilter: SearchFilter{IDs: chunk},
+			})
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, item := range items {
+				found[commonOf(item).ID] = item
+			}
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
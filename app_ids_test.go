@@ -0,0 +1,53 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetByIDs(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 350)
+	for i := 0; i < 350; i++ {
+		items = append(items, fakeItem(i, start, float64(i)))
+	}
+	items[10]["__deletedAt"] = start.Format(time.RFC3339)
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	t.Run("order_and_not_found", func(t *testing.T) {
+		ids := make([]string, 0, 260)
+		for i := 349; i >= 100; i-- {
+			ids = append(ids, fakeID(i))
+		}
+		ids = append(ids, fakeID(500), fakeID(200), fakeID(10), fakeID(501))
+
+		lists := fa.Lists
+		found, notFound, err := goods.GetByIDs(ctxBg, ids)
+		require.NoError(t, err)
+		require.Equal(t, lists+3, fa.Lists)
+		require.Len(t, found, 251)
+		require.Equal(t, 349, found[0].Price)
+		require.Equal(t, 100, found[249].Price)
+		require.Equal(t, fakeID(10), found[250].ID)
+		require.Equal(t, []string{fakeID(500), fakeID(501)}, notFound)
+	})
+
+	t.Run("invalid_id", func(t *testing.T) {
+		_, _, err := goods.GetByIDs(ctxBg, []string{fakeID(1), "bad-id"})
+		require.ErrorIs(t, err, ErrInvalidID)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		found, notFound, err := goods.GetByIDs(ctxBg, nil)
+		require.NoError(t, err)
+		require.Empty(t, found)
+		require.Empty(t, notFound)
+	})
+
+}
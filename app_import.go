@@ -0,0 +1,809 @@
+package e365_gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const importChunkSize = 100
+
+// ImportColumn - правило загрузки колонки файла в поле элемента
+type ImportColumn struct {
+	// Field - код поля (или имя поля в T), в которое загружается колонка
+	Field string
+	// Lookup - поиск ссылок по внешнему ключу: значения колонки заменяются на id найденных элементов (см. LookupBy)
+	Lookup LinkLookup
+}
+
+// ImportMapping - колонки файла (заголовки CSV или ключи JSONL) и поля, в которые они загружаются.
+// Колонки, которых нет в mapping, пропускаются. Если mapping не задан, колонки загружаются в поля T
+// с теми же кодами, а колонки служебных полей (кроме __name) и колонки, которых нет в T, пропускаются
+type ImportMapping map[string]ImportColumn
+
+// ImportOptions - параметры загрузки
+type ImportOptions[T interface{}] struct {
+	// Format - формат файла: ExportCSV (по умолчанию) или ExportJSONL
+	Format ExportFormat
+	// Key - код поля, по которому ищутся существующие элементы (например, внешний id или __id).
+	// Найденные элементы обновляются (только загружаемыми полями), остальные создаются. Строки без значения ключа
+	// отклоняются. Если не задан, все элементы создаются
+	Key string
+	// Concurrency - кол-во одновременных запросов на создание и изменение (по умолчанию 4)
+	Concurrency int
+	// DryRun - только проверить файл (включая поиск ссылок и существующих элементов), без создания и изменения элементов
+	DryRun bool
+	// Rejects - файл отклоненных строк: CSV с номером строки и ошибкой
+	Rejects io.Writer
+	// Validate - дополнительная проверка элемента перед записью. Ошибка отклоняет строку
+	Validate func(item *T) error
+	// DateFormat - формат дат (по умолчанию time.RFC3339, также принимаются "2006-01-02 15:04:05" и "2006-01-02")
+	DateFormat string
+	// Location - часовой пояс дат без смещения (по умолчанию UTC)
+	Location *time.Location
+	// Separator - разделитель значений множественных полей (по умолчанию ",")
+	Separator string
+	// Comma - разделитель колонок CSV (по умолчанию ',')
+	Comma rune
+	// Currency - валюта денег, если она не указана в значении (например, "12.50" вместо "12.50 RUB")
+	Currency string
+}
+
+// ImportError - ошибка загрузки строки файла
+type ImportError struct {
+	// Row - номер строки: для CSV номер записи, считая заголовок первой, для JSONL номер строки файла
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e ImportError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d: %s: %s", e.Row, e.Column, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReport - отчет о загрузке
+type ImportReport struct {
+	DryRun bool
+	Rows   int
+	// Created и Updated - кол-во созданных и обновленных элементов (в режиме DryRun - которые были бы созданы и обновлены)
+	Created  int
+	Updated  int
+	Rejected int
+	Errors   []ImportError
+}
+
+// Err возвращает ошибки отклоненных строк, объединенные через errors.Join, или nil
+func (r ImportReport) Err() error {
+	errs := make([]error, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+// Import загружает элементы из CSV или JSONL файла r. Колонки загружаются в поля T по mapping,
+// строковые значения приводятся к типу поля: числа (допускаются пробелы между разрядами и десятичная запятая),
+// даты (DateFormat в Location), "Да/Нет" (true/false, да/нет), деньги ("12.50 RUB"), ФИО ("Фамилия Имя Отчество"),
+// категории (коды вариантов), телефоны, email, ссылки (id или внешние ключи через ImportColumn.Lookup).
+// Множественные значения разделяются через Separator. Пустые значения не загружаются.
+// Значения JSONL, которые не являются строками, загружаются как есть.
+//
+// Строки обрабатываются пачками по 100: значения проверяются декодированием в T и opts.Validate,
+// затем элементы создаются или обновляются (см. ImportOptions.Key) не более чем в Concurrency запросов одновременно.
+// Ошибки строк попадают в отчет и в opts.Rejects, ошибка возвращается, только если не удалось прочитать файл
+// или выполнить поиск
+func (app App[T]) Import(ctx context.Context, r io.Reader, mapping ImportMapping, opts ImportOptions[T]) (ImportReport, error) {
+
+	report := ImportReport{DryRun: opts.DryRun}
+	im, err := newImporter(app, mapping, opts)
+	if err != nil {
+		return report, err
+	}
+
+	var rows rowReader
+	switch opts.Format {
+	case "", ExportCSV:
+		rows = newCSVRows(r, opts.Comma)
+	case ExportJSONL:
+		rows = &jsonlRows{r: bufio.NewReader(r)}
+	default:
+		return report, wrap(string(opts.Format), ErrUnsupportedFormat)
+	}
+
+	var rejects *csv.Writer
+	if opts.Rejects != nil {
+		rejects = csv.NewWriter(opts.Rejects)
+		if err = rejects.Write([]string{"row", "error"}); err != nil {
+			return report, err
+		}
+	}
+
+	for {
+		chunk := make([]importRow, 0, importChunkSize)
+		var readErr error
+		for len(chunk) < importChunkSize {
+			row, err := rows.next()
+			if err != nil {
+				readErr = err
+				break
+			}
+			chunk = append(chunk, row)
+		}
+
+		results, err := im.process(ctx, chunk)
+		if err != nil {
+			return report, err
+		}
+		for _, res := range results {
+			report.Rows++
+			switch {
+			case res.err != nil:
+				report.Rejected++
+				report.Errors = append(report.Errors, *res.err)
+				if rejects != nil {
+					_ = rejects.Write([]string{strconv.Itoa(res.err.Row), res.err.Error()})
+				}
+			case res.updated:
+				report.Updated++
+			default:
+				report.Created++
+			}
+		}
+		if rejects != nil {
+			rejects.Flush()
+			if err = rejects.Error(); err != nil {
+				return report, err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return report, nil
+		}
+		if readErr != nil {
+			return report, readErr
+		}
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+}
+
+// importRow - строка файла: значения колонок в json представлении (значения CSV - json строки)
+type importRow struct {
+	num   int
+	cells DynamicItem
+	err   error
+}
+
+type rowReader interface {
+	// next возвращает следующую строку или io.EOF
+	next() (importRow, error)
+}
+
+type csvRows struct {
+	r      *csv.Reader
+	header []string
+	num    int
+}
+
+func newCSVRows(r io.Reader, comma rune) *csvRows {
+	cr := csv.NewReader(r)
+	if comma != 0 {
+		cr.Comma = comma
+	}
+	return &csvRows{r: cr}
+}
+
+func (cr *csvRows) next() (importRow, error) {
+	if cr.header == nil {
+		header, err := cr.r.Read()
+		if err != nil {
+			return importRow{}, err
+		}
+		cr.num++
+		// Excel сохраняет CSV в UTF-8 с BOM
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		cr.header = header
+	}
+
+	record, err := cr.r.Read()
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return importRow{}, err
+	}
+	cr.num++
+	row := importRow{num: cr.num, err: err}
+	for i, cell := range record {
+		if i < len(cr.header) {
+			_ = row.cells.Set(cr.header[i], cell)
+		}
+	}
+	return row, nil
+}
+
+type jsonlRows struct {
+	r   *bufio.Reader
+	num int
+}
+
+func (jr *jsonlRows) next() (importRow, error) {
+	for {
+		line, err := jr.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return importRow{}, err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return importRow{}, err
+		}
+		jr.num++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		row := importRow{num: jr.num}
+		row.err = json.Unmarshal(line, &row.cells)
+		return row, nil
+	}
+}
+
+// importResult - результат загрузки строки
+type importResult struct {
+	updated bool
+	err     *ImportError
+}
+
+// importer - состояние загрузки между пачками строк
+type importer[T interface{}] struct {
+	app     App[T]
+	opts    ImportOptions[T]
+	mapping ImportMapping
+	item    reflect.Type
+	dynamic bool
+	key     string
+	keys    LinkLookup
+	seen    map[string]int
+	fields  map[string]*itemField
+}
+
+func newImporter[T interface{}](app App[T], mapping ImportMapping, opts ImportOptions[T]) (*importer[T], error) {
+	im := &importer[T]{
+		app:     app,
+		opts:    opts,
+		mapping: mapping,
+		item:    reflect.TypeOf((*T)(nil)).Elem(),
+		seen:    make(map[string]int),
+		fields:  make(map[string]*itemField),
+	}
+	_, im.dynamic = interface{}((*T)(nil)).(*DynamicItem)
+	if im.opts.Concurrency < 1 {
+		im.opts.Concurrency = bulkConcurrency
+	}
+	if im.opts.DateFormat == "" {
+		im.opts.DateFormat = time.RFC3339
+	}
+	if im.opts.Location == nil {
+		im.opts.Location = time.UTC
+	}
+	if im.opts.Separator == "" {
+		im.opts.Separator = ","
+	}
+
+	for _, col := range mapping {
+		if _, err := im.field(col.Field); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Key != "" {
+		f, err := im.field(opts.Key)
+		if err != nil {
+			return nil, err
+		}
+		im.key = opts.Key
+		if f != nil {
+			im.key = f.code
+		}
+		im.keys = LookupBy(app, im.key)
+	}
+	return im, nil
+}
+
+// field ищет поле T по коду или имени. Для DynamicItem возвращает nil
+func (im *importer[T]) field(name string) (*itemField, error) {
+	if im.dynamic {
+		return nil, nil
+	}
+	if f, ok := im.fields[name]; ok {
+		return f, nil
+	}
+	f, ok := lookupItemField(im.item, name)
+	if !ok {
+		return nil, &FieldError{Item: im.item.String(), Field: name, Err: ErrUnknownField}
+	}
+	im.fields[name] = &f
+	return &f, nil
+}
+
+// column возвращает правило загрузки колонки. ok = false, если колонка пропускается
+func (im *importer[T]) column(name string) (ImportColumn, bool) {
+	if im.mapping != nil {
+		col, ok := im.mapping[name]
+		return col, ok
+	}
+	if strings.HasPrefix(name, "__") && name != "__name" && name != im.key {
+		return ImportColumn{}, false
+	}
+	if _, err := im.field(name); err != nil {
+		return ImportColumn{}, false
+	}
+	return ImportColumn{Field: name}, true
+}
+
+// process загружает пачку строк и возвращает результаты в порядке строк
+func (im *importer[T]) process(ctx context.Context, rows []importRow) ([]importResult, error) {
+
+	results := make([]importResult, len(rows))
+	reject := func(i int, column string, err error) {
+		results[i].err = &ImportError{Row: rows[i].num, Column: column, Err: err}
+	}
+
+	// ссылки по внешним ключам ищутся сразу для всей пачки
+	links, err := im.lookupLinks(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make([]DynamicItem, len(rows))
+	keys := make([]string, len(rows))
+	values := make(map[string]interface{})
+	for i, row := range rows {
+		if row.err != nil {
+			reject(i, "", wrap(row.err.Error(), ErrImportValue))
+			continue
+		}
+		body, column, err := im.convert(row.cells, links)
+		if err != nil {
+			reject(i, column, err)
+			continue
+		}
+		item, err := FromDynamic[T](body)
+		if err != nil {
+			reject(i, "", wrap(err.Error(), ErrImportValue))
+			continue
+		}
+		if im.opts.Validate != nil {
+			if err = im.opts.Validate(&item); err != nil {
+				reject(i, "", err)
+				continue
+			}
+		}
+		if im.key != "" {
+			// ключ без значения не позволяет найти существующий элемент: такая строка создала бы дубликат
+			raw, _ := body.Raw(im.key)
+			key, value, ok := importKey(raw)
+			if !ok {
+				reject(i, im.key, wrap("key value is missing or is not a string, number or category", ErrImportValue))
+				continue
+			}
+			keys[i] = key
+			if prev, ok := im.seen[key]; ok {
+				reject(i, im.key, wrap(fmt.Sprintf("%s (row %d)", keys[i], prev), ErrDuplicateKey))
+				continue
+			}
+			im.seen[key] = row.num
+			values[key] = value
+		}
+		body.Delete(fieldID)
+		bodies[i] = body
+	}
+
+	existing := make(map[string][]string)
+	if im.keys != nil {
+		if existing, err = im.keys.lookupIDs(ctx, values); err != nil {
+			return nil, err
+		}
+	}
+
+	dyn := appAs[DynamicItem](im.app)
+	eg := errgroup.Group{}
+	eg.SetLimit(im.opts.Concurrency)
+	for i := range rows {
+		if results[i].err != nil {
+			continue
+		}
+		i, body := i, bodies[i]
+		ids := existing[keys[i]]
+		if keys[i] != "" && len(ids) > 1 {
+			reject(i, im.key, wrap(keys[i], ErrAmbiguousKey))
+			continue
+		}
+		if im.key == fieldID && keys[i] != "" && len(ids) == 0 {
+			reject(i, im.key, wrap(keys[i], ErrItemNotFound))
+			continue
+		}
+		results[i].updated = len(ids) == 1
+		if im.opts.DryRun {
+			continue
+		}
+		eg.Go(func() error {
+			var err error
+			if results[i].updated {
+				_, err = dyn.Update(ctx, ids[0], body)
+			} else {
+				_, err = dyn.Create(ctx, body)
+			}
+			if err != nil {
+				reject(i, "", err)
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return results, nil
+}
+
+// lookupLinks ищет id элементов по внешним ключам из колонок с ImportColumn.Lookup
+func (im *importer[T]) lookupLinks(ctx context.Context, rows []importRow) (map[LinkLookup]map[string][]string, error) {
+
+	keys := make(map[LinkLookup]map[string]interface{})
+	for _, row := range rows {
+		for _, name := range row.cells.Keys() {
+			col, ok := im.column(name)
+			if !ok || col.Lookup == nil {
+				continue
+			}
+			raw, _ := row.cells.Raw(name)
+			values, err := im.linkKeys(raw)
+			if err != nil {
+				// ошибка будет отражена при разборе строки
+				continue
+			}
+			if keys[col.Lookup] == nil {
+				keys[col.Lookup] = make(map[string]interface{})
+			}
+			for _, v := range values {
+				keys[col.Lookup][v] = v
+			}
+		}
+	}
+
+	links := make(map[LinkLookup]map[string][]string, len(keys))
+	for lookup, values := range keys {
+		found, err := lookup.lookupIDs(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		links[lookup] = found
+	}
+	return links, nil
+}
+
+// linkKeys возвращает внешние ключи из значения колонки: строки через Separator или массива строк JSONL
+func (im *importer[T]) linkKeys(raw json.RawMessage) ([]string, error) {
+	var cell string
+	if err := json.Unmarshal(raw, &cell); err == nil {
+		return im.split(cell), nil
+	}
+	keys := make([]string, 0)
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, wrap("expected keys of linked items", ErrImportValue)
+	}
+	return keys, nil
+}
+
+func (im *importer[T]) split(cell string) []string {
+	parts := make([]string, 0)
+	for _, p := range strings.Split(cell, im.opts.Separator) {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// convert переводит значения колонок строки в поля элемента. При ошибке возвращает колонку, в которой она произошла
+func (im *importer[T]) convert(cells DynamicItem, links map[LinkLookup]map[string][]string) (DynamicItem, string, error) {
+
+	var body DynamicItem
+	for _, name := range cells.Keys() {
+		col, ok := im.column(name)
+		if !ok {
+			continue
+		}
+		f, err := im.field(col.Field)
+		if err != nil {
+			return body, name, err
+		}
+		code := col.Field
+		if f != nil {
+			code = f.code
+		}
+
+		raw, _ := cells.Raw(name)
+		if col.Lookup != nil {
+			keys, err := im.linkKeys(raw)
+			if err != nil {
+				return body, name, err
+			}
+			if len(keys) == 0 {
+				continue
+			}
+			ids := make([]string, 0, len(keys))
+			for _, key := range keys {
+				found := links[col.Lookup][key]
+				switch {
+				case len(found) == 0:
+					return body, name, wrap(key, ErrItemNotFound)
+				case len(found) > 1:
+					return body, name, wrap(key, ErrAmbiguousKey)
+				}
+				ids = append(ids, found[0])
+			}
+			if err = body.Set(code, ids); err != nil {
+				return body, name, err
+			}
+			continue
+		}
+
+		var cell string
+		if err = json.Unmarshal(raw, &cell); err != nil {
+			// значения JSONL, которые не являются строками, загружаются как есть
+			body.setRaw(code, raw)
+			continue
+		}
+		if cell = strings.TrimSpace(cell); cell == "" {
+			continue
+		}
+		if f == nil {
+			body.setRaw(code, raw)
+			continue
+		}
+		value, err := im.parse(f.typ, cell)
+		if err != nil {
+			return body, name, wrap(fmt.Sprintf("%q: %s", cell, err), ErrImportValue)
+		}
+		if err = body.Set(code, value); err != nil {
+			return body, name, err
+		}
+	}
+	return body, "", nil
+}
+
+// parse приводит строковое значение к типу поля t
+func (im *importer[T]) parse(t reflect.Type, cell string) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return im.parseTime(cell)
+	case dateType:
+		tm, err := im.parseTime(cell)
+		return types.NewDate(tm), err
+	case moneyType:
+		return im.parseMoney(cell)
+	case fullNameType:
+		parts := strings.Fields(cell)
+		fn := types.FullName{Lastname: parts[0]}
+		if len(parts) > 1 {
+			fn.Firstname = parts[1]
+		}
+		if len(parts) > 2 {
+			fn.Middlename = strings.Join(parts[2:], " ")
+		}
+		return fn, nil
+	case phonesType:
+		phones := make(types.Phones, 0)
+		for _, tel := range im.split(cell) {
+			phones = append(phones, types.Phone{Type: types.PhoneMain, Tel: tel})
+		}
+		return phones, nil
+	case emailsType:
+		emails := make(types.Emails, 0)
+		for _, email := range im.split(cell) {
+			emails = append(emails, types.Email{Type: types.EmailMain, Email: email})
+		}
+		return emails, nil
+	case categoryType:
+		return types.Category{Code: cell}, nil
+	case categoriesType:
+		categories := make(types.Categories, 0)
+		for _, code := range im.split(cell) {
+			categories = append(categories, types.Category{Code: code})
+		}
+		return categories, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(normalizeNumber(cell), 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(normalizeNumber(cell), 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(normalizeNumber(cell), t.Bits())
+	case reflect.Bool:
+		switch strings.ToLower(cell) {
+		case "да":
+			return true, nil
+		case "нет":
+			return false, nil
+		}
+		return strconv.ParseBool(cell)
+	case reflect.String:
+		return cell, nil
+	}
+
+	// остальные типы (ссылки, пользователи, списки): json или список значений через разделитель
+	if (cell[0] == '{' || cell[0] == '[') && json.Valid([]byte(cell)) {
+		return json.RawMessage(cell), nil
+	}
+	return im.split(cell), nil
+}
+
+func (im *importer[T]) parseTime(cell string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{im.opts.DateFormat, time.RFC3339, time.DateTime, time.DateOnly} {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, cell, im.opts.Location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (im *importer[T]) parseMoney(cell string) (types.Money, error) {
+	currency := im.opts.Currency
+	if i := strings.LastIndexAny(cell, " \u00a0"); i > 0 {
+		if code := cell[i:]; strings.IndexFunc(code, func(r rune) bool { return r >= 'A' && r <= 'Z' }) >= 0 {
+			currency = strings.TrimSpace(code)
+			cell = cell[:i]
+		}
+	}
+	amount, err := strconv.ParseFloat(normalizeNumber(cell), 64)
+	if err != nil {
+		return types.Money{}, err
+	}
+	return types.NewMoney(amount, currency), nil
+}
+
+var numberReplacer = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".")
+
+// normalizeNumber убирает пробелы между разрядами и заменяет десятичную запятую на точку
+func normalizeNumber(s string) string {
+	return numberReplacer.Replace(s)
+}
+
+// LinkLookup - поиск id элементов по значению ключевого поля, создается через LookupBy
+type LinkLookup interface {
+	// lookupIDs возвращает id элементов по значениям ключа: нормализованный ключ (см. importKey) -> значение
+	// для фильтра. Ключи, для которых элементы не найдены, в результате отсутствуют
+	lookupIDs(ctx context.Context, values map[string]interface{}) (map[string][]string, error)
+}
+
+// appLookup - поиск элементов приложения по значению поля с кэшем найденных id.
+// Элементы декодируются в DynamicItem, поэтому поле может отсутствовать в структуре элементов приложения
+type appLookup struct {
+	app   App[DynamicItem]
+	field string
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+// LookupBy создает поиск элементов приложения app по значению строкового поля field (например, внешнего id или __id).
+// Найденные id кэшируются, поэтому один LinkLookup можно использовать в нескольких загрузках
+func LookupBy[L interface{}](app App[L], field string) LinkLookup {
+	return &appLookup{app: appAs[DynamicItem](app), field: field, cache: make(map[string][]string)}
+}
+
+func (l *appLookup) lookupIDs(ctx context.Context, values map[string]interface{}) (map[string][]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	missing := make([]string, 0)
+	for key := range values {
+		if _, ok := l.cache[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	for start := 0; start < len(missing); start += idsChunkSize {
+		end := start + idsChunkSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		chunk := missing[start:end]
+
+		s := l.app.Search()
+		if l.field == fieldID {
+			s = s.Where(SearchFilter{IDs: chunk})
+		} else {
+			list := make([]interface{}, 0, len(chunk))
+			for _, key := range chunk {
+				list = append(list, values[key])
+			}
+			s = s.Match(In(l.field, list...))
+		}
+		err := s.Select(l.field).Size(maxPageSize).Iter().Each(ctx, func(items []DynamicItem) error {
+			for _, d := range items {
+				raw, _ := d.Raw(l.field)
+				if key, _, ok := importKey(raw); ok {
+					l.cache[key] = append(l.cache[key], d.Common().ID)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	found := make(map[string][]string, len(values))
+	for key := range values {
+		if ids, ok := l.cache[key]; ok {
+			found[key] = ids
+		}
+	}
+	return found, nil
+}
+
+// importKey приводит значение ключевого поля из JSON к строке, одинаковой для загружаемой строки и найденных элементов
+// (например, 5 и 5.0 дают "5"), и возвращает значение для фильтра. Поддерживаются строки, числа, логические значения,
+// категории ({"code": ...}), телефоны и email (единственное значение массива). ok = false, если значения нет
+func importKey(raw json.RawMessage) (key string, value interface{}, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", nil, false
+	}
+	for {
+		switch val := v.(type) {
+		case string:
+			return val, val, val != ""
+		case json.Number:
+			f, err := val.Float64()
+			if err != nil {
+				return "", nil, false
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64), f, true
+		case bool:
+			return strconv.FormatBool(val), val, true
+		case []interface{}:
+			if len(val) != 1 {
+				return "", nil, false
+			}
+			v = val[0]
+		case map[string]interface{}:
+			for _, k := range []string{"code", "tel", "email"} {
+				if s, ok := val[k].(string); ok && s != "" {
+					return s, s, true
+				}
+			}
+			return "", nil, false
+		default:
+			return "", nil, false
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+type importItem struct {
+	AppCommon
+	ExternalID string           `json:"external_id"`
+	Price      float64          `json:"price"`
+	Cost       types.Money      `json:"cost"`
+	Owner      types.FullName   `json:"owner"`
+	Kind       types.Categories `json:"kind"`
+	Parent     AppRefs[Product] `json:"parent"`
+	Deadline   time.Time        `json:"deadline"`
+	Active     bool             `json:"active"`
+}
+
+func TestImport(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 3)
+	for i := 0; i < 3; i++ {
+		item := fakeItem(i, start, float64(i))
+		item["external_id"] = "e" + string(rune('1'+i))
+		items = append(items, item)
+	}
+	fa, settings := newFakeApp(t, items)
+	app := NewApp[importItem](settings)
+
+	products := []map[string]interface{}{fakeItem(100, start, 0), fakeItem(101, start, 0), fakeItem(102, start, 0)}
+	products[0]["sku"] = "p1"
+	products[1]["sku"] = "p2"
+	products[2]["sku"] = "p2"
+	_, productSettings := newFakeApp(t, products)
+	ctxBg := context.Background()
+
+	const file = "external_id;Цена;Стоимость;Владелец;Вид;Товар;Срок;Активен\n" +
+		"e1;1 200,50;12,50 USD;Петров Иван Сергеевич;a, b;p1;01.08.2023 10:00;да\n" +
+		"n1;10;5;Иванов Петр;a;p1;02.08.2023 00:00;false\n" +
+		"n2;abc;;;;;;\n" +
+		"n3;1;;;;p9;;\n" +
+		"n4;1;;;;p2;;\n" +
+		"n1;11;;;;;;\n" +
+		"n5;-5;;;;;;\n" +
+		"n6;1\n"
+
+	mapping := ImportMapping{
+		"external_id": {Field: "external_id"},
+		"Цена":        {Field: "Price"},
+		"Стоимость":   {Field: "cost"},
+		"Владелец":    {Field: "owner"},
+		"Вид":         {Field: "kind"},
+		"Товар":       {Field: "parent", Lookup: LookupBy(NewApp[Product](productSettings), "sku")},
+		"Срок":        {Field: "deadline"},
+		"Активен":     {Field: "active"},
+	}
+	opts := ImportOptions[importItem]{
+		Key:        "external_id",
+		Comma:      ';',
+		DateFormat: "02.01.2006 15:04",
+		Location:   time.FixedZone("MSK", 3*60*60),
+		Currency:   "RUB",
+		Validate: func(item *importItem) error {
+			if item.Price < 0 {
+				return errors.New("negative price")
+			}
+			return nil
+		},
+	}
+
+	t.Run("dry_run", func(t *testing.T) {
+		dryRun := opts
+		dryRun.DryRun = true
+		rejects := &bytes.Buffer{}
+		dryRun.Rejects = rejects
+
+		report, err := app.Import(ctxBg, strings.NewReader(file), mapping, dryRun)
+		require.NoError(t, err)
+		require.Equal(t, 8, report.Rows)
+		require.Equal(t, 1, report.Created)
+		require.Equal(t, 1, report.Updated)
+		require.Equal(t, 6, report.Rejected)
+		require.Zero(t, fa.Hits[methodCreate]+fa.Hits[methodUpdate])
+
+		rows := make([]int, 0, len(report.Errors))
+		for _, e := range report.Errors {
+			rows = append(rows, e.Row)
+		}
+		require.Equal(t, []int{4, 5, 6, 7, 8, 9}, rows)
+		require.ErrorIs(t, report.Errors[0], ErrImportValue)
+		require.Equal(t, "Цена", report.Errors[0].Column)
+		require.ErrorIs(t, report.Errors[1], ErrItemNotFound)
+		require.ErrorIs(t, report.Errors[2], ErrAmbiguousKey)
+		require.ErrorIs(t, report.Errors[3], ErrDuplicateKey)
+		require.EqualError(t, report.Errors[4], "row 8: negative price")
+		require.ErrorIs(t, report.Errors[5], ErrImportValue)
+		require.ErrorIs(t, report.Err(), ErrDuplicateKey)
+
+		lines := strings.Split(strings.TrimSpace(rejects.String()), "\n")
+		require.Len(t, lines, 7)
+		require.Equal(t, "row,error", lines[0])
+		require.True(t, strings.HasPrefix(lines[2], "5,row 5: Товар: item not found: p9"))
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		report, err := app.Import(ctxBg, strings.NewReader(file), mapping, opts)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Created)
+		require.Equal(t, 1, report.Updated)
+		require.Equal(t, 1, fa.Hits[methodCreate])
+		require.Equal(t, 1, fa.Hits[methodUpdate])
+
+		updated, err := app.GetByID(ctxBg, fakeID(0))
+		require.NoError(t, err)
+		require.Equal(t, 1200.5, updated.Price)
+		require.Equal(t, types.Money{Cents: 1250, Currency: "USD"}, updated.Cost)
+		require.Equal(t, types.FullName{Lastname: "Петров", Firstname: "Иван", Middlename: "Сергеевич"}, updated.Owner)
+		require.Equal(t, []string{"a", "b"}, []string{updated.Kind[0].Code, updated.Kind[1].Code})
+		require.Equal(t, []string{fakeID(100)}, updated.Parent.IDs())
+		require.True(t, updated.Deadline.Equal(time.Date(2023, 8, 1, 7, 0, 0, 0, time.UTC)))
+		require.True(t, updated.Active)
+
+		created := fa.Items[len(fa.Items)-1]
+		require.Equal(t, "n1", created["external_id"])
+		require.Equal(t, map[string]interface{}{"cents": float64(500), "currency": "RUB"}, created["cost"])
+
+		// повторная загрузка обновляет созданный элемент
+		report, err = app.Import(ctxBg, strings.NewReader(file), mapping, opts)
+		require.NoError(t, err)
+		require.Equal(t, 0, report.Created)
+		require.Equal(t, 2, report.Updated)
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		const file = `{"external_id":"e2","price":7,"kind":[{"code":"z","name":"Z"}],"extra":1,"__id":"x"}` + "\n" +
+			"\n" +
+			`{"external_id":"e3","price":"8,5"}` + "\n" +
+			`{"external_id":` + "\n"
+
+		report, err := app.Import(ctxBg, strings.NewReader(file), nil, ImportOptions[importItem]{Format: ExportJSONL, Key: "external_id"})
+		require.NoError(t, err)
+		require.Equal(t, 3, report.Rows)
+		require.Equal(t, 2, report.Updated)
+		require.Len(t, report.Errors, 1)
+		require.Equal(t, 4, report.Errors[0].Row)
+
+		updated, _, err := app.GetByIDs(ctxBg, []string{fakeID(1), fakeID(2)})
+		require.NoError(t, err)
+		require.Equal(t, 7.0, updated[0].Price)
+		require.Equal(t, "z", updated[0].Kind[0].Code)
+		require.Equal(t, 8.5, updated[1].Price)
+		require.NotContains(t, fa.Items[1], "extra")
+
+		_, err = app.Import(ctxBg, strings.NewReader(file), ImportMapping{"x": {Field: "unknown"}}, ImportOptions[importItem]{})
+		require.ErrorIs(t, err, ErrUnknownField)
+	})
+
+	t.Run("number_key", func(t *testing.T) {
+		// ключ - число: значения из файла и найденных элементов сравниваются после приведения к одной строке
+		fa, settings := newFakeApp(t, []map[string]interface{}{fakeItem(0, start, 10), fakeItem(1, start, 20)})
+		app := NewApp[importItem](settings)
+		const file = "price,external_id\n10,a\n30,b\n,c\n"
+
+		report, err := app.Import(ctxBg, strings.NewReader(file), nil, ImportOptions[importItem]{Key: "price"})
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Updated)
+		require.Equal(t, 1, report.Created)
+		require.Len(t, report.Errors, 1)
+		require.Equal(t, "price", report.Errors[0].Column)
+		require.ErrorIs(t, report.Errors[0].Err, ErrImportValue)
+
+		// повторная загрузка обновляет те же элементы, а не создает новые
+		report, err = app.Import(ctxBg, strings.NewReader(file), nil, ImportOptions[importItem]{Key: "price"})
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Updated)
+		require.Zero(t, report.Created)
+		require.Len(t, fa.Items, 3)
+	})
+}
@@ -0,0 +1,78 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// WithRaw - элемент приложения T вместе с полями, которых нет в структуре T.
+// Используется как контекст приложения: NewApp[WithRaw[T]](settings).
+// Неизвестные поля сохраняются в Extra при получении элементов (GetByID, Search и т.д.)
+// и отправляются обратно при Create и Update, поэтому не теряются при чтении и последующем изменении элемента.
+// Поля T перекрывают одноименные поля из Extra
+type WithRaw[T interface{}] struct {
+	Item  T
+	Extra map[string]json.RawMessage
+}
+
+// UnknownKeys возвращает отсортированные коды полей, которых нет в структуре T.
+// Непустой результат означает, что приложение было изменено (например, в дизайнере) и T стоит обновить
+func (w WithRaw[T]) UnknownKeys() []string {
+	keys := make([]string, 0, len(w.Extra))
+	for k := range w.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rawItem возвращает тип элемента, чтобы поиск по полям (Strict, Select, Include) работал с полями T
+func (w WithRaw[T]) rawItem() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (w WithRaw[T]) appCommon() AppCommon {
+	return commonOf(w.Item)
+}
+
+func (w WithRaw[T]) MarshalJSON() ([]byte, error) {
+	bts, err := json.Marshal(w.Item)
+	if err != nil || len(w.Extra) == 0 {
+		return bts, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(bts, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range w.Extra {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (w *WithRaw[T]) UnmarshalJSON(bts []byte) error {
+	*w = WithRaw[T]{}
+	if string(bytes.TrimSpace(bts)) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(bts, &w.Item); err != nil {
+		return err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(bts, &fields); err != nil {
+		return err
+	}
+	for _, f := range itemFieldsOf(w.rawItem()) {
+		delete(fields, f.code)
+	}
+	if len(fields) > 0 {
+		w.Extra = fields
+	}
+	return nil
+}
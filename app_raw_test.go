@@ -0,0 +1,70 @@
+package e365_gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRaw(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 5)
+	for i := 0; i < 5; i++ {
+		item := fakeItem(i, start, float64(i*10))
+		item["color"] = "red"
+		item["size"] = map[string]interface{}{"w": float64(i)}
+		items = append(items, item)
+	}
+	_, settings := newFakeApp(t, items)
+	goods := NewApp[WithRaw[Product]](settings)
+	ctxBg := context.Background()
+
+	t.Run("get_and_update", func(t *testing.T) {
+		item, err := goods.GetByID(ctxBg, fakeID(2))
+		require.NoError(t, err)
+		require.Equal(t, 20, item.Item.Price)
+		require.Equal(t, []string{"color", "size"}, item.UnknownKeys())
+		require.JSONEq(t, `{"w": 2}`, string(item.Extra["size"]))
+
+		item.Item.Price = 25
+		bts, err := json.Marshal(item)
+		require.NoError(t, err)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(bts, &body))
+		require.Equal(t, "red", body["color"])
+		require.Equal(t, float64(25), body["price"])
+
+		updated, err := goods.Update(ctxBg, item.Item.ID, item)
+		require.NoError(t, err)
+		require.Equal(t, 25, updated.Item.Price)
+		require.Equal(t, 2, updated.Item.Version)
+		require.Contains(t, updated.Extra, "color")
+	})
+
+	t.Run("search", func(t *testing.T) {
+		found, err := goods.Search().
+			Where(SearchFilter{Fields: Fields{"price": Field.Number().From(30)}}).
+			OrderBy("price").
+			Strict().
+			All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		require.Equal(t, fakeID(3), found[0].Item.ID)
+		require.Equal(t, []string{"color", "size"}, found[0].UnknownKeys())
+
+		_, err = goods.Search().Where(SearchFilter{Fields: Fields{"color": "red"}}).Strict().All(ctxBg)
+		require.ErrorIs(t, err, ErrUnknownField)
+	})
+
+	t.Run("no_extra", func(t *testing.T) {
+		var w WithRaw[Product]
+		require.NoError(t, json.Unmarshal([]byte(`{"__id": "`+fakeID(1)+`", "price": 1}`), &w))
+		require.Nil(t, w.Extra)
+		require.Empty(t, w.UnknownKeys())
+	})
+
+}
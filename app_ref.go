@@ -0,0 +1,263 @@
+package e365_gateway
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// AppRef - значение одиночного поля типа "Приложение", ссылка на элемент приложения с контекстом T.
+// Элемент можно получить через Resolve или заранее загрузить для всей страницы через Search().Include
+type AppRef[T interface{}] struct {
+	ID   string
+	item *T
+}
+
+// NewAppRef создает ссылку на элемент приложения с переданным id
+func NewAppRef[T interface{}](id string) AppRef[T] {
+	return AppRef[T]{ID: id}
+}
+
+func (r AppRef[T]) MarshalJSON() ([]byte, error) {
+	if r.ID == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal([1]string{r.ID})
+}
+
+func (r *AppRef[T]) UnmarshalJSON(bts []byte) error {
+	ids, err := unmarshalRefIDs(bts)
+	if err != nil {
+		return err
+	}
+	*r = AppRef[T]{}
+	if len(ids) > 0 {
+		r.ID = ids[0]
+	}
+	return nil
+}
+
+// Item возвращает элемент, если он был загружен через Resolve или Include
+func (r AppRef[T]) Item() (T, bool) {
+	if r.item == nil {
+		var nilT T
+		return nilT, false
+	}
+	return *r.item, true
+}
+
+// Resolve получает элемент по ссылке и запоминает его
+func (r *AppRef[T]) Resolve(ctx context.Context, app App[T]) (T, error) {
+	item, err := app.GetByID(ctx, r.ID)
+	if err != nil {
+		return item, err
+	}
+	r.item = &item
+	return item, nil
+}
+
+func (r *AppRef[T]) refIDs() []string {
+	if r.ID == "" {
+		return nil
+	}
+	return []string{r.ID}
+}
+
+func (r *AppRef[T]) resolveRefs(items map[string]interface{}) {
+	if item, ok := items[r.ID].(T); ok {
+		r.item = &item
+	}
+}
+
+// AppRefs - значение множественного поля типа "Приложение"
+type AppRefs[T interface{}] []AppRef[T]
+
+// NewAppRefs создает ссылки на элементы приложения с переданными id
+func NewAppRefs[T interface{}](ids ...string) AppRefs[T] {
+	refs := make(AppRefs[T], 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, NewAppRef[T](id))
+	}
+	return refs
+}
+
+func (rs AppRefs[T]) MarshalJSON() ([]byte, error) {
+	if rs == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(rs.IDs())
+}
+
+func (rs *AppRefs[T]) UnmarshalJSON(bts []byte) error {
+	ids, err := unmarshalRefIDs(bts)
+	if err != nil {
+		return err
+	}
+	if ids == nil {
+		*rs = nil
+		return nil
+	}
+	*rs = NewAppRefs[T](ids...)
+	return nil
+}
+
+// IDs возвращает id элементов, на которые указывают ссылки
+func (rs AppRefs[T]) IDs() []string {
+	ids := make([]string, 0, len(rs))
+	for _, r := range rs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// Items возвращает загруженные элементы (в порядке ссылок)
+func (rs AppRefs[T]) Items() []T {
+	items := make([]T, 0, len(rs))
+	for _, r := range rs {
+		if item, ok := r.Item(); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Resolve получает все элементы по ссылкам (пачками по 100 через SearchFilter.IDs) и запоминает их.
+// Возвращаются только найденные элементы
+func (rs AppRefs[T]) Resolve(ctx context.Context, app App[T]) ([]T, error) {
+	found, err := app.loadRefs(ctx, rs.IDs())
+	if err != nil {
+		return nil, err
+	}
+	for i := range rs {
+		rs[i].resolveRefs(found)
+	}
+	return rs.Items(), nil
+}
+
+func (rs *AppRefs[T]) refIDs() []string {
+	return rs.IDs()
+}
+
+func (rs *AppRefs[T]) resolveRefs(items map[string]interface{}) {
+	for i := range *rs {
+		(*rs)[i].resolveRefs(items)
+	}
+}
+
+// unmarshalRefIDs декодирует значение поля типа "Приложение": null, строку или массив id
+func unmarshalRefIDs(bts []byte) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(bts, &v); err != nil {
+		return nil, err
+	}
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if val == "" {
+			return nil, nil
+		}
+		return []string{val}, nil
+	}
+	ids := make([]string, 0)
+	if err := json.Unmarshal(bts, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// refField - поле элемента, ссылки в котором можно заполнить через Include
+type refField interface {
+	refIDs() []string
+	resolveRefs(items map[string]interface{})
+}
+
+// refLoader - источник элементов для Include, реализуется App
+type refLoader interface {
+	loadRefs(ctx context.Context, ids []string) (map[string]interface{}, error)
+}
+
+// loadRefs получает элементы по id (включая удаленные), см. fetchByIDs
+func (app App[T]) loadRefs(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	items, err := app.fetchByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]interface{}, len(items))
+	for id, item := range items {
+		found[id] = item
+	}
+	return found, nil
+}
+
+type include struct {
+	field  string
+	loader refLoader
+}
+
+// Include загружает элементы, на которые ссылается поле field (типа AppRef или AppRefs) у всех найденных
+// элементов: id собираются со всей страницы и запрашиваются у app пачками, без запроса на каждый элемент.
+// Загруженные элементы доступны через AppRef.Item и AppRefs.Items
+func (s searchInstance[T]) Include(field string, app refLoader) searchInstance[T] {
+	includes := make([]include, 0, len(s.includes)+1)
+	includes = append(includes, s.includes...)
+	s.includes = append(includes, include{field: field, loader: app})
+	return s
+}
+
+// applyIncludes заполняет ссылки, переданные через Include
+func (s searchInstance[T]) applyIncludes(ctx context.Context, items []T) error {
+	if len(s.includes) == 0 || len(items) == 0 {
+		return nil
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for _, inc := range s.includes {
+		f, ok := lookupItemField(t, inc.field)
+		if !ok {
+			return &FieldError{Item: t.String(), Field: inc.field, Err: ErrUnknownField}
+		}
+
+		refs := make([]refField, 0, len(items))
+		ids := make([]string, 0, len(items))
+		seen := make(map[string]struct{}, len(items))
+		for i := range items {
+			v := reflect.ValueOf(&items[i]).Elem()
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					break
+				}
+				v = v.Elem()
+			}
+			if v.Kind() != reflect.Struct {
+				continue
+			}
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				continue
+			}
+			ref, ok := fv.Addr().Interface().(refField)
+			if !ok {
+				return &FieldError{Item: t.String(), Field: inc.field, Reason: "field is not AppRef or AppRefs", Err: ErrFieldType}
+			}
+			refs = append(refs, ref)
+			for _, id := range ref.refIDs() {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+
+		found, err := inc.loader.loadRefs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			ref.resolveRefs(found)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package e365_gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppRef(t *testing.T) {
+
+	type Order struct {
+		AppCommon
+		Customer AppRef[Product]  `json:"customer"`
+		Goods    AppRefs[Product] `json:"goods"`
+	}
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	products := make([]map[string]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		products = append(products, fakeItem(i, start, float64(i)))
+	}
+	orders := make([]map[string]interface{}, 0, 30)
+	for i := 0; i < 30; i++ {
+		order := fakeItem(1000+i, start, 0)
+		order["customer"] = []interface{}{fakeID(i)}
+		order["goods"] = []interface{}{fakeID(100 + i), fakeID(120 + i), fakeID(9999)}
+		orders = append(orders, order)
+	}
+	orders[0]["customer"] = nil
+
+	fp, productsSettings := newFakeApp(t, products)
+	_, ordersSettings := newFakeApp(t, orders)
+	goods := NewApp[Product](productsSettings)
+	orderApp := NewApp[Order](ordersSettings)
+	ctxBg := context.Background()
+
+	t.Run("json", func(t *testing.T) {
+		bts, err := json.Marshal(Order{Customer: NewAppRef[Product](fakeID(1)), Goods: NewAppRefs[Product](fakeID(2), fakeID(3))})
+		require.NoError(t, err)
+
+		var raw map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(bts, &raw))
+		require.JSONEq(t, `["`+fakeID(1)+`"]`, string(raw["customer"]))
+		require.JSONEq(t, `["`+fakeID(2)+`","`+fakeID(3)+`"]`, string(raw["goods"]))
+
+		var empty Order
+		bts, err = json.Marshal(empty)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(bts, &raw))
+		require.Equal(t, "null", string(raw["customer"]))
+
+		var decoded Order
+		require.NoError(t, json.Unmarshal([]byte(`{"customer":"`+fakeID(5)+`","goods":null}`), &decoded))
+		require.Equal(t, fakeID(5), decoded.Customer.ID)
+		require.Nil(t, decoded.Goods)
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		ref := NewAppRef[Product](fakeID(7))
+		_, ok := ref.Item()
+		require.False(t, ok)
+
+		item, err := ref.Resolve(ctxBg, goods)
+		require.NoError(t, err)
+		require.Equal(t, 7, item.Price)
+		cached, ok := ref.Item()
+		require.True(t, ok)
+		require.Equal(t, item, cached)
+
+		refs := NewAppRefs[Product](fakeID(1), fakeID(9999), fakeID(2))
+		items, err := refs.Resolve(ctxBg, goods)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		require.Equal(t, fakeID(2), items[1].ID)
+	})
+
+	t.Run("include", func(t *testing.T) {
+		lists := fp.Lists
+		found, err := orderApp.Search().
+			Include("customer", goods).
+			Include("Goods", goods).
+			AllAtOnce(ctxBg, 2)
+		require.NoError(t, err)
+		require.Len(t, found, 30)
+		// 29 заказчиков и 60 товаров (+1 несуществующий) - одна пачка на каждое поле
+		require.Equal(t, lists+2, fp.Lists)
+
+		for _, order := range found {
+			customer, ok := order.Customer.Item()
+			if order.Customer.ID == "" {
+				require.False(t, ok)
+				continue
+			}
+			require.True(t, ok)
+			require.Equal(t, order.Customer.ID, customer.ID)
+			require.Len(t, order.Goods.Items(), 2)
+		}
+
+		_, err = orderApp.Search().Include("customr", goods).First(ctxBg)
+		require.ErrorIs(t, err, ErrUnknownField)
+		_, err = orderApp.Search().Include("__name", goods).First(ctxBg)
+		require.ErrorIs(t, err, ErrFieldType)
+	})
+
+}
@@ -0,0 +1,276 @@
+package e365_gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/inse91/elma_lib/types"
+)
+
+// FieldType - тип поля приложения в elma365
+type FieldType string
+
+const (
+	FieldTypeString     FieldType = "STRING"
+	FieldTypeInteger    FieldType = "INTEGER"
+	FieldTypeFloat      FieldType = "FLOAT"
+	FieldTypeMoney      FieldType = "MONEY"
+	FieldTypeBoolean    FieldType = "BOOLEAN"
+	FieldTypeDateTime   FieldType = "DATETIME"
+	FieldTypeDuration   FieldType = "DURATION"
+	FieldTypeEnum       FieldType = "ENUM"
+	FieldTypeCategory   FieldType = "CATEGORY"
+	FieldTypeUser       FieldType = "SYS_USER"
+	FieldTypeCollection FieldType = "SYS_COLLECTION"
+	FieldTypePhone      FieldType = "PHONE"
+	FieldTypeEmail      FieldType = "EMAIL"
+	FieldTypeFullName   FieldType = "FULL_NAME"
+	FieldTypeTable      FieldType = "TABLE"
+	FieldTypeFile       FieldType = "FILE"
+	FieldTypeStatus     FieldType = "STATUS"
+	FieldTypeJSON       FieldType = "JSON"
+)
+
+// AppSchema - описание полей приложения
+type AppSchema struct {
+	Namespace string        `json:"namespace"`
+	Code      string        `json:"code"`
+	Fields    []FieldSchema `json:"fields"`
+}
+
+// Field возвращает описание поля по коду
+func (s AppSchema) Field(code string) (FieldSchema, bool) {
+	for _, f := range s.Fields {
+		if f.Code == code {
+			return f, true
+		}
+	}
+	return FieldSchema{}, false
+}
+
+// FieldSchema - описание поля приложения
+type FieldSchema struct {
+	Code     string    `json:"code"`
+	Name     string    `json:"name,omitempty"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required,omitempty"`
+	Multiple bool      `json:"multiple,omitempty"`
+	// LinkedApp - приложение, на которое ссылается поле типа "Приложение" (SYS_COLLECTION)
+	LinkedApp *LinkedApp `json:"linkedApp,omitempty"`
+	// Values - варианты значений поля типа "Категория" (ENUM)
+	Values []EnumValue `json:"values,omitempty"`
+}
+
+// LinkedApp - приложение, на которое ссылается поле
+type LinkedApp struct {
+	Namespace string `json:"namespace"`
+	Code      string `json:"code"`
+}
+
+// EnumValue - вариант значения поля типа "Категория"
+type EnumValue struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// schemaField - описание поля в ответе /settings/fields
+type schemaField struct {
+	Code     string    `json:"code"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required"`
+	Single   *bool     `json:"single"`
+	Array    bool      `json:"array"`
+	View     struct {
+		Name string `json:"name"`
+	} `json:"view"`
+	Data struct {
+		Namespace string      `json:"namespace"`
+		Code      string      `json:"code"`
+		EnumItems []EnumValue `json:"enumItems"`
+	} `json:"data"`
+}
+
+type getFieldsResponse struct {
+	respCommon
+	Fields []schemaField `json:"fields"`
+}
+
+// Schema получает описание полей приложения (код, тип, обязательность, множественность,
+// связанное приложение и варианты категорий)
+func (app App[T]) Schema(ctx context.Context) (AppSchema, error) {
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, app.method.getFields, nil)
+	if err != nil {
+		return AppSchema{}, wrap(err.Error(), ErrCreateRequest)
+	}
+	request.Header = app.stand.header()
+
+	gfr, err := doRequest[getFieldsResponse](app.client, request)
+	if err != nil {
+		return AppSchema{}, err
+	}
+	if !gfr.Success {
+		return AppSchema{}, wrap(gfr.Error, ErrResponseNotSuccess)
+	}
+
+	schema := AppSchema{
+		Namespace: app.namespace,
+		Code:      app.code,
+		Fields:    make([]FieldSchema, 0, len(gfr.Fields)),
+	}
+	for _, sf := range gfr.Fields {
+		f := FieldSchema{
+			Code:     sf.Code,
+			Name:     sf.View.Name,
+			Type:     sf.Type,
+			Required: sf.Required,
+			Multiple: sf.Array || (sf.Single != nil && !*sf.Single),
+			Values:   sf.Data.EnumItems,
+		}
+		if sf.Type == FieldTypeCollection && sf.Data.Code != "" {
+			f.LinkedApp = &LinkedApp{Namespace: sf.Data.Namespace, Code: sf.Data.Code}
+		}
+		schema.Fields = append(schema.Fields, f)
+	}
+
+	return schema, nil
+
+}
+
+// SchemaMismatch - расхождение поля структуры с описанием приложения
+type SchemaMismatch struct {
+	Field  string
+	GoType string
+	Type   FieldType
+	Reason string
+}
+
+func (m SchemaMismatch) String() string {
+	if m.Type == "" {
+		return fmt.Sprintf("%s (%s): %s", m.Field, m.GoType, m.Reason)
+	}
+	return fmt.Sprintf("%s (%s -> %s): %s", m.Field, m.GoType, m.Type, m.Reason)
+}
+
+// SchemaMismatchError - структура элемента не соответствует описанию приложения (см. ValidateType)
+type SchemaMismatchError struct {
+	Item       string
+	App        string
+	Mismatches []SchemaMismatch
+}
+
+func (e *SchemaMismatchError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s: %s does not match app %s:", ErrSchemaMismatch, e.Item, e.App))
+	for _, m := range e.Mismatches {
+		sb.WriteString("\n\t")
+		sb.WriteString(m.String())
+	}
+	return sb.String()
+}
+
+func (e *SchemaMismatchError) Unwrap() error {
+	return ErrSchemaMismatch
+}
+
+// ValidateType проверяет, что все поля структуры T есть в приложении и их типы подходят к типам полей elma365.
+// Служебные поля (__id, __name и т.д.), которых нет в описании, не проверяются.
+// Удобно вызывать при старте сервиса, чтобы не работать с приложением, измененным в дизайнере
+func ValidateType[T interface{}](schema AppSchema) error {
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	mismatches := make([]SchemaMismatch, 0)
+	for _, f := range itemFieldsOf(t) {
+		fs, ok := schema.Field(f.code)
+		if !ok {
+			if strings.HasPrefix(f.code, "__") {
+				continue
+			}
+			mismatches = append(mismatches, SchemaMismatch{Field: f.code, GoType: f.typ.String(), Reason: "no such field in app"})
+			continue
+		}
+		if !fieldFits(fs, f.typ) {
+			mismatches = append(mismatches, SchemaMismatch{Field: f.code, GoType: f.typ.String(), Type: fs.Type, Reason: "go type does not fit field type"})
+		}
+	}
+
+	if len(mismatches) == 0 {
+		return nil
+	}
+	return &SchemaMismatchError{
+		Item:       t.String(),
+		App:        schema.Namespace + "." + schema.Code,
+		Mismatches: mismatches,
+	}
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	phonesType     = reflect.TypeOf(types.Phones{})
+	emailsType     = reflect.TypeOf(types.Emails{})
+	fullNameType   = reflect.TypeOf(types.FullName{})
+	categoryType   = reflect.TypeOf(types.Category{})
+	categoriesType = reflect.TypeOf(types.Categories{})
+	userRefType    = reflect.TypeOf(types.UserRef{})
+	userRefsType   = reflect.TypeOf(types.UserRefs{})
+	durationType   = reflect.TypeOf(types.Duration(0))
+	statusType     = reflect.TypeOf(Status{})
+	fileType       = reflect.TypeOf(File{})
+	filesType      = reflect.TypeOf([]File{})
+	refFieldType   = reflect.TypeOf((*refField)(nil)).Elem()
+)
+
+// fieldFits проверяет, что значение поля elma365 можно декодировать в тип t
+// (поля типа "Приложение", "Пользователи" и "Категория" приходят массивами)
+func fieldFits(fs FieldSchema, t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || t == rawMessageType {
+		return true
+	}
+
+	kind := fieldKindOf(t)
+	isList := t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+	switch fs.Type {
+	case FieldTypeString:
+		return kind == kindString
+	case FieldTypeInteger, FieldTypeFloat:
+		return kind == kindNumber && t != moneyType && t != durationType
+	case FieldTypeDuration:
+		return kind == kindNumber && t != moneyType
+	case FieldTypeMoney:
+		return t == moneyType
+	case FieldTypeBoolean:
+		return kind == kindBool
+	case FieldTypeDateTime:
+		return kind == kindTime
+	case FieldTypeEnum, FieldTypeCategory:
+		return t == categoryType || t == categoriesType
+	case FieldTypeUser:
+		return t == userRefType || t == userRefsType || isList
+	case FieldTypeCollection:
+		return reflect.PointerTo(t).Implements(refFieldType) || isList
+	case FieldTypePhone:
+		return t == phonesType
+	case FieldTypeEmail:
+		return t == emailsType
+	case FieldTypeFullName:
+		return t == fullNameType
+	case FieldTypeTable:
+		return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	case FieldTypeFile:
+		// id файла или массив id, либо описание файла (File)
+		return kind == kindString || isList || t == fileType || t == filesType
+	case FieldTypeStatus:
+		return t == statusType
+	case FieldTypeJSON:
+		// в поле типа "JSON" может храниться любое значение
+		return true
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package e365_gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+type Order struct {
+	AppCommon
+	Title    string           `json:"title"`
+	Total    types.Money      `json:"total"`
+	Qty      int              `json:"qty"`
+	Deadline time.Time        `json:"deadline"`
+	Kind     types.Category   `json:"kind"`
+	Product  AppRef[Product]  `json:"product"`
+	Managers types.UserRefs   `json:"managers"`
+	Phones   types.Phones     `json:"phones"`
+	Extra    interface{}      `json:"extra"`
+	Items    AppRefs[Product] `json:"items"`
+}
+
+func TestSchema(t *testing.T) {
+
+	fa, settings := newFakeApp(t, nil)
+	fa.Fields = []map[string]interface{}{
+		{"code": "__name", "type": "STRING", "required": true, "single": true, "view": map[string]interface{}{"name": "Название"}},
+		{"code": "title", "type": "STRING", "single": true},
+		{"code": "total", "type": "MONEY", "single": true},
+		{"code": "qty", "type": "INTEGER", "required": true, "single": true},
+		{"code": "deadline", "type": "DATETIME", "single": true},
+		{"code": "kind", "type": "ENUM", "single": true, "data": map[string]interface{}{
+			"enumItems": []map[string]interface{}{{"code": "big", "name": "Большой"}, {"code": "small", "name": "Малый"}},
+		}},
+		{"code": "product", "type": "SYS_COLLECTION", "single": true, "data": map[string]interface{}{"namespace": "goods", "code": "products"}},
+		{"code": "items", "type": "SYS_COLLECTION", "single": false, "data": map[string]interface{}{"namespace": "goods", "code": "products"}},
+		{"code": "managers", "type": "SYS_USER", "single": false},
+		{"code": "phones", "type": "PHONE", "single": false},
+		{"code": "extra", "type": "JSON", "single": true},
+	}
+	orders := NewApp[Order](settings)
+
+	schema, err := orders.Schema(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "ns", schema.Namespace)
+	require.Len(t, schema.Fields, 11)
+
+	name, ok := schema.Field("__name")
+	require.True(t, ok)
+	require.Equal(t, "Название", name.Name)
+	require.True(t, name.Required)
+	require.False(t, name.Multiple)
+
+	kind, _ := schema.Field("kind")
+	require.Equal(t, FieldTypeEnum, kind.Type)
+	require.Equal(t, []EnumValue{{Code: "big", Name: "Большой"}, {Code: "small", Name: "Малый"}}, kind.Values)
+
+	items, _ := schema.Field("items")
+	require.True(t, items.Multiple)
+	require.Equal(t, &LinkedApp{Namespace: "goods", Code: "products"}, items.LinkedApp)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, ValidateType[Order](schema))
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		type brokenOrder struct {
+			AppCommon
+			Title   int             `json:"title"`
+			Total   float64         `json:"total"`
+			Kind    string          `json:"kind"`
+			Comment string          `json:"comment"`
+			Product AppRef[Product] `json:"product"`
+		}
+		err := ValidateType[brokenOrder](schema)
+		require.ErrorIs(t, err, ErrSchemaMismatch)
+
+		var sme *SchemaMismatchError
+		require.True(t, errors.As(err, &sme))
+		require.Equal(t, "ns.app", sme.App)
+		fields := make([]string, 0, len(sme.Mismatches))
+		for _, m := range sme.Mismatches {
+			fields = append(fields, m.Field)
+		}
+		require.Equal(t, []string{"title", "total", "kind", "comment"}, fields)
+		require.Contains(t, err.Error(), "\n\tcomment (string): no such field in app")
+		require.Contains(t, err.Error(), "\n\ttitle (int -> STRING): go type does not fit field type")
+	})
+
+	t.Run("category_file_status", func(t *testing.T) {
+		fields := AppSchema{Namespace: "ns", Code: "app", Fields: []FieldSchema{
+			{Code: "__status", Type: FieldTypeStatus},
+			{Code: "tags", Type: FieldTypeCategory},
+			{Code: "photo", Type: FieldTypeFile},
+			{Code: "docs", Type: FieldTypeFile},
+			{Code: "extra", Type: FieldTypeJSON},
+		}}
+		type valid struct {
+			Status Status           `json:"__status"`
+			Tags   types.Categories `json:"tags"`
+			Photo  string           `json:"photo"`
+			Docs   []string         `json:"docs"`
+			Extra  map[string]int   `json:"extra"`
+		}
+		require.NoError(t, ValidateType[valid](fields))
+
+		type withFiles struct {
+			Photo File   `json:"photo"`
+			Docs  []File `json:"docs"`
+		}
+		require.NoError(t, ValidateType[withFiles](fields))
+
+		type broken struct {
+			Status int            `json:"__status"`
+			Tags   string         `json:"tags"`
+			Photo  bool           `json:"photo"`
+			Docs   int            `json:"docs"`
+			Extra  map[string]int `json:"extra"`
+		}
+		var sme *SchemaMismatchError
+		require.True(t, errors.As(ValidateType[broken](fields), &sme))
+		require.Len(t, sme.Mismatches, 4)
+	})
+
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"golang.org/x/sync/errgroup"
 	"net/http"
+	"strings"
 	"sync"
 )
 
@@ -47,15 +48,29 @@ func (app App[T]) Search() searchInstance[T] {
 // All получает все элементы по переданному фильтру.
 // Кол-во элементов задается через Size (не более 100, по умолчанию 10)
 func (s searchInstance[T]) All(ctx context.Context) ([]T, error) {
-	items, _, err := s.app.find(ctx, filter{
-		From:         s.from,
-		Size:         s.size,
-		Active:       !s.includeDeleted,
-		SearchFilter: s.search,
-	})
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	parts, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) > 1 {
+		items, err := collectParts(ctx, parts, s.from+s.size)
+		if err != nil || len(items) <= s.from {
+			return []T{}, err
+		}
+		return items[s.from:], nil
+	}
+	s = parts[0]
+
+	items, _, err := s.app.find(ctx, s.newFilter(s.from, s.size))
 	if err != nil {
 		return nil, err
 	}
+	if err = s.applyIncludes(ctx, items); err != nil {
+		return nil, err
+	}
 	return items, nil
 }
 
@@ -64,12 +79,22 @@ func (s searchInstance[T]) All(ctx context.Context) ([]T, error) {
 // Количество одновременно работающих горутин можно контроллировать через goroutineLimit (по умолчанию 1)
 func (s searchInstance[T]) AllAtOnce(ctx context.Context, goroutineLimit int) ([]T, error) {
 
+	parts, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) > 1 {
+		return collectParts(ctx, parts, -1)
+	}
+	s = parts[0]
+
 	count, err := s.Count(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	numberOfCycles := 1 + count/100
+	s = s.stable()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -86,12 +111,7 @@ func (s searchInstance[T]) AllAtOnce(ctx context.Context, goroutineLimit int) ([
 	for i := 0; i < numberOfCycles; i++ {
 		i := i
 		eg.Go(func() error {
-			items, _, err := s.app.find(ctx, filter{
-				From:         i * 100,
-				Size:         100,
-				Active:       !s.includeDeleted,
-				SearchFilter: s.search,
-			})
+			items, _, err := s.app.find(ctx, s.newFilter(i*100, 100))
 			if err != nil {
 				cancel()
 				return err
@@ -107,28 +127,45 @@ func (s searchInstance[T]) AllAtOnce(ctx context.Context, goroutineLimit int) ([
 	}
 
 	err = eg.Wait()
-	if err == nil || errors.Is(err, ErrNoMoreItems) {
-		return all, nil
+	if err != nil && !errors.Is(err, ErrNoMoreItems) {
+		return nil, err
 	}
-	return nil, err
+	if err = s.applyIncludes(ctx, all); err != nil {
+		return nil, err
+	}
+	return all, nil
 }
 
 // First получает один элмент по переданному фильтру
 func (s searchInstance[T]) First(ctx context.Context) (T, error) {
 
 	var t T
-	items, _, err := s.app.find(ctx, filter{
-		From:         s.from,
-		Size:         1,
-		Active:       !s.includeDeleted,
-		SearchFilter: s.search,
-	})
+	if err := s.check(); err != nil {
+		return t, err
+	}
+	parts, err := s.resolve(ctx)
+	if err != nil {
+		return t, err
+	}
+	if len(parts) > 1 {
+		items, err := collectParts(ctx, parts, s.from+1)
+		if err != nil || len(items) <= s.from {
+			return t, err
+		}
+		return items[s.from], nil
+	}
+	s = parts[0]
+
+	items, _, err := s.app.find(ctx, s.newFilter(s.from, 1))
 	if err != nil {
 		return t, err
 	}
 	if len(items) == 0 {
 		return t, nil
 	}
+	if err = s.applyIncludes(ctx, items); err != nil {
+		return t, err
+	}
 
 	return items[0], nil
 
@@ -138,12 +175,28 @@ func (s searchInstance[T]) First(ctx context.Context) (T, error) {
 // Аналог COUNT в SQL.
 func (s searchInstance[T]) Count(ctx context.Context) (int, error) {
 
-	_, count, err := s.app.find(ctx, filter{
-		From:         s.from,
-		Size:         0,
-		Active:       !s.includeDeleted,
-		SearchFilter: s.search,
-	})
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	parts, err := s.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(parts) > 1 {
+		// элемент может попасть в несколько пачек, поэтому считаем уникальные id
+		for i := range parts {
+			parts[i] = parts[i].Select(fieldID)
+			parts[i].includes = nil
+		}
+		items, err := collectParts(ctx, parts, -1)
+		if err != nil {
+			return 0, err
+		}
+		return len(items), nil
+	}
+	s = parts[0]
+
+	_, count, err := s.app.find(ctx, s.newFilter(s.from, 0))
 	if err != nil {
 		return 0, err
 	}
@@ -155,14 +208,59 @@ func (s searchInstance[T]) Count(ctx context.Context) (int, error) {
 type searchInstance[T interface{}] struct {
 	search         SearchFilter
 	includeDeleted bool
+	strict         bool
+	selected       []string
+	text           string
+	includes       []include
 	size           int
 	from           int
 	app            *App[T]
 }
 
-// Where применяет фильтр к поиску
+// newFilter собирает тело запроса на /list с учетом всех параметров поиска.
+// Если задана сортировка, к ней добавляется __id, чтобы порядок элементов между страницами не менялся
+func (s searchInstance[T]) newFilter(from, size int) filter {
+	f := filter{
+		From:         from,
+		Size:         size,
+		Active:       !s.includeDeleted,
+		SearchFilter: s.search,
+		Projection:   projection(s.selected),
+		Text:         s.text,
+	}
+	if size > 0 {
+		f.SortExpressions = withTieBreaker(f.SortExpressions)
+	}
+	return f
+}
+
+// Where добавляет фильтр к поиску и ничего из заданного ранее (в том числе предыдущими Where, Match и OrderBy) не отбрасывает:
+// фильтры sf.Fields добавляются к заданным (фильтры по одному полю пересекаются), sf.Query объединяется
+// с заданными условиями через логическое И, а IDs, AtStatus, StatusGroupId и SortExpressions заменяют
+// заданные ранее, только если указаны в sf
 func (s searchInstance[T]) Where(sf SearchFilter) searchInstance[T] {
-	s.search = sf
+	for code, value := range sf.Fields {
+		s = s.withField(code, value)
+	}
+	if sf.Query != nil {
+		prev := s.search.Query
+		s.search.Query = sf.Query
+		if prev != nil {
+			s = s.Match(prev)
+		}
+	}
+	if len(sf.IDs) > 0 {
+		s.search.IDs = sf.IDs
+	}
+	if len(sf.AtStatus) > 0 {
+		s.search.AtStatus = sf.AtStatus
+	}
+	if sf.StatusGroupId != "" {
+		s.search.StatusGroupId = sf.StatusGroupId
+	}
+	if len(sf.SortExpressions) > 0 {
+		s.search.SortExpressions = sf.SortExpressions
+	}
 	return s
 }
 
@@ -187,6 +285,12 @@ func (s searchInstance[T]) From(from int) searchInstance[T] {
 	return s
 }
 
+// Text добавляет к поиску полнотекстовый запрос. Сочетается с Where, Match и остальными параметрами поиска
+func (s searchInstance[T]) Text(query string) searchInstance[T] {
+	s.text = strings.TrimSpace(query)
+	return s
+}
+
 // IncludeDeleted добавляет выборку удаленные элменты (__deletedAt != null)
 func (s searchInstance[T]) IncludeDeleted() searchInstance[T] {
 	s.includeDeleted = true
@@ -0,0 +1,284 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/inse91/elma_lib/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const countGoroutineLimit = 4
+
+// CountByStatus возвращает кол-во элементов по переданному фильтру в каждом статусе приложения (код статуса -> кол-во).
+// Статусы берутся из GetStatusInfo, для каждого статуса параллельно выполняется Count с AtStatus.
+// Если в фильтре уже задан AtStatus, запрашиваются только эти статусы, для остальных возвращается 0
+func (s searchInstance[T]) CountByStatus(ctx context.Context) (map[string]int, error) {
+
+	info, err := s.app.GetStatusInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]bool, len(s.search.AtStatus))
+	for _, code := range s.search.AtStatus {
+		allowed[code] = true
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(countGoroutineLimit)
+
+	counts := make(map[string]int, len(info.StatusItems))
+	mu := sync.Mutex{}
+	for _, status := range info.StatusItems {
+		code := status.Code
+		if len(allowed) > 0 && !allowed[code] {
+			counts[code] = 0
+			continue
+		}
+		eg.Go(func() error {
+			part := s
+			part.search.AtStatus = []string{code}
+			count, err := part.Count(ctx)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			counts[code] = count
+			return nil
+		})
+	}
+
+	if err = eg.Wait(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Sum возвращает сумму значений числового поля (в том числе типа "Деньги") по всем найденным элементам
+func (s searchInstance[T]) Sum(ctx context.Context, field string) (float64, error) {
+	sum := 0.0
+	err := s.eachNumber(ctx, field, func(v float64) {
+		sum += v
+	})
+	return sum, err
+}
+
+// Min возвращает минимальное значение числового поля. Если ни у одного элемента поле не заполнено, вернется ErrItemNotFound
+func (s searchInstance[T]) Min(ctx context.Context, field string) (float64, error) {
+	return s.extremum(ctx, field, func(v, cur float64) bool { return v < cur })
+}
+
+// Max возвращает максимальное значение числового поля. Если ни у одного элемента поле не заполнено, вернется ErrItemNotFound
+func (s searchInstance[T]) Max(ctx context.Context, field string) (float64, error) {
+	return s.extremum(ctx, field, func(v, cur float64) bool { return v > cur })
+}
+
+// Avg возвращает среднее значение числового поля (элементы с незаполненным полем не учитываются).
+// Если ни у одного элемента поле не заполнено, вернется ErrItemNotFound
+func (s searchInstance[T]) Avg(ctx context.Context, field string) (float64, error) {
+	sum, n := 0.0, 0
+	err := s.eachNumber(ctx, field, func(v float64) {
+		sum += v
+		n++
+	})
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, wrap(field, ErrItemNotFound)
+	}
+	return sum / float64(n), nil
+}
+
+func (s searchInstance[T]) extremum(ctx context.Context, field string, better func(v, cur float64) bool) (float64, error) {
+	var res float64
+	found := false
+	err := s.eachNumber(ctx, field, func(v float64) {
+		if !found || better(v, res) {
+			res = v
+			found = true
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, wrap(field, ErrItemNotFound)
+	}
+	return res, nil
+}
+
+// GroupBy возвращает кол-во найденных элементов для каждого значения поля типа "Категория" (по коду варианта),
+// "Приложение" или "Пользователи" (по id). Элемент с несколькими значениями учитывается в каждом из них,
+// элементы с незаполненным полем учитываются под ключом ""
+func (s searchInstance[T]) GroupBy(ctx context.Context, field string) (map[string]int, error) {
+	groups := make(map[string]int)
+	err := s.eachValue(ctx, field, func(_ DynamicItem, item T, v reflect.Value) error {
+		keys, err := groupKeys(item, field, v)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			groups[""]++
+		}
+		for _, k := range keys {
+			groups[k]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// eachNumber передает в fn заполненные значения числового поля всех найденных элементов
+func (s searchInstance[T]) eachNumber(ctx context.Context, field string, fn func(v float64)) error {
+	return s.eachValue(ctx, field, func(d DynamicItem, item T, v reflect.Value) error {
+		n, ok, err := numberValue(d, item, field, v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fn(n)
+		}
+		return nil
+	})
+}
+
+// eachValue постранично обходит найденные элементы (запрашивая только поле field) и передает в fn элемент в виде
+// DynamicItem (чтобы отличить незаполненное поле от нулевого значения), в виде T и значение поля.
+// Для DynamicItem значение поля не передается (fn получает нулевой reflect.Value)
+func (s searchInstance[T]) eachValue(ctx context.Context, field string, fn func(d DynamicItem, item T, v reflect.Value) error) error {
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	_, dynamic := interface{}((*T)(nil)).(*DynamicItem)
+	var f itemField
+	if !dynamic {
+		var ok bool
+		if f, ok = lookupItemField(t, field); !ok || f.code != field {
+			return &FieldError{Item: t.String(), Field: field, Err: ErrUnknownField}
+		}
+	}
+
+	s.selected = nil
+	s.includes = nil
+	search := SearchAs[DynamicItem](s.Select(field))
+	return search.Size(maxPageSize).Iter().Each(ctx, func(items []DynamicItem) error {
+		for _, d := range items {
+			var item T
+			var v reflect.Value
+			if dynamic {
+				item = interface{}(d).(T)
+			} else {
+				var err error
+				if item, err = FromDynamic[T](d); err != nil {
+					return err
+				}
+				v = reflect.ValueOf(&item).Elem()
+				for v.Kind() == reflect.Pointer && !v.IsNil() {
+					v = v.Elem()
+				}
+				if v.Kind() != reflect.Struct {
+					continue
+				}
+				if v, err = v.FieldByIndexErr(f.index); err != nil {
+					continue
+				}
+			}
+			if err := fn(d, item, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// numberValue возвращает значение числового поля. ok = false, если поле не заполнено (нет в ответе сервера или null),
+// поэтому нулевое значение поля структуры без указателя не считается заполненным
+func numberValue[T interface{}](d DynamicItem, item T, field string, v reflect.Value) (float64, bool, error) {
+	if _, ok := interface{}(item).(DynamicItem); ok {
+		if n, ok := d.Number(field); ok {
+			return n, true, nil
+		}
+		if m, ok := d.Money(field); ok {
+			return m.Decimal(), true, nil
+		}
+		return 0, false, nil
+	}
+
+	if fieldKindOf(v.Type()) != kindNumber {
+		return 0, false, &FieldError{Item: reflect.TypeOf(item).String(), Field: field, Reason: v.Type().String() + " is not a number", Err: ErrFieldType}
+	}
+	if raw, ok := d.Raw(field); !ok || string(bytes.TrimSpace(raw)) == "null" {
+		return 0, false, nil
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return 0, false, nil
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == moneyType:
+		return v.Interface().(types.Money).Decimal(), true, nil
+	case v.CanInt():
+		return float64(v.Int()), true, nil
+	case v.CanUint():
+		return float64(v.Uint()), true, nil
+	}
+	return v.Float(), true, nil
+}
+
+// groupKeys возвращает значения поля, по которым группируются элементы
+func groupKeys[T interface{}](item T, field string, v reflect.Value) ([]string, error) {
+	if d, ok := interface{}(item).(DynamicItem); ok {
+		if cs, ok := d.Categories(field); ok {
+			return cs.Codes(), nil
+		}
+		if ids, ok := d.Refs(field); ok {
+			return ids, nil
+		}
+		if s, ok := d.String(field); ok && s != "" {
+			return []string{s}, nil
+		}
+		return nil, nil
+	}
+
+	if v.CanAddr() {
+		if ref, ok := v.Addr().Interface().(refField); ok {
+			return ref.refIDs(), nil
+		}
+	}
+	switch val := v.Interface().(type) {
+	case types.Category:
+		if val.Code == "" {
+			return nil, nil
+		}
+		return []string{val.Code}, nil
+	case types.Categories:
+		return val.Codes(), nil
+	case types.UserRef:
+		if val.ID == "" {
+			return nil, nil
+		}
+		return []string{val.ID}, nil
+	case types.UserRefs:
+		return val, nil
+	case []string:
+		return val, nil
+	case string:
+		if val == "" {
+			return nil, nil
+		}
+		return []string{val}, nil
+	case bool:
+		return []string{fmt.Sprint(val)}, nil
+	}
+	return nil, &FieldError{Item: reflect.TypeOf(item).String(), Field: field, Reason: v.Type().String() + " can not be grouped", Err: ErrFieldType}
+}
@@ -0,0 +1,125 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+type aggregateItem struct {
+	AppCommon
+	Price  int             `json:"price"`
+	Cost   types.Money     `json:"cost"`
+	Weight *float64        `json:"weight"`
+	Stock  int             `json:"stock"`
+	Kind   types.Category  `json:"kind"`
+	Parent AppRef[Product] `json:"parent"`
+}
+
+func TestAggregations(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 250)
+	for i := 0; i < 250; i++ {
+		item := fakeItem(i, start.Add(time.Duration(i)*time.Minute), float64(i))
+		item["cost"] = map[string]interface{}{"cents": float64(i * 100), "currency": "RUB"}
+		item["__status"] = map[string]interface{}{"order": float64(0), "status": float64(1 + i%3)}
+		if i%2 == 0 {
+			item["weight"] = float64(i)
+			item["kind"] = []interface{}{map[string]interface{}{"code": "even", "name": "Четный"}}
+		}
+		if i >= 200 {
+			item["stock"] = float64(i)
+		}
+		if i < 10 {
+			item["parent"] = []interface{}{fakeID(1000 + i%2)}
+		}
+		items = append(items, item)
+	}
+	fa, settings := newFakeApp(t, items)
+	fa.StatusInfo = testStatusInfo
+	app := NewApp[aggregateItem](settings)
+	ctxBg := context.Background()
+
+	t.Run("count_by_status", func(t *testing.T) {
+		counts, err := app.Search().CountByStatus(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"new": 84, "in_work": 83, "closed": 83}, counts)
+
+		counts, err = app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(9)}}).CountByStatus(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"new": 4, "in_work": 3, "closed": 3}, counts)
+
+		counts, err = app.Search().Where(SearchFilter{AtStatus: []string{"new", "closed"}}).CountByStatus(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"new": 84, "in_work": 0, "closed": 83}, counts)
+	})
+
+	t.Run("numbers", func(t *testing.T) {
+		lists := fa.Lists
+		sum, err := app.Search().Sum(ctxBg, "price")
+		require.NoError(t, err)
+		require.Equal(t, float64(249*250/2), sum)
+		require.Equal(t, lists+3, fa.Lists)
+		require.Equal(t, map[string]interface{}{"*": false, "__id": true, "price": true}, fa.Bodies[len(fa.Bodies)-1]["fields"])
+
+		sum, err = app.Search().Sum(ctxBg, "cost")
+		require.NoError(t, err)
+		require.Equal(t, float64(249*250/2), sum)
+
+		min, err := app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().From(100)}}).Min(ctxBg, "price")
+		require.NoError(t, err)
+		require.Equal(t, 100.0, min)
+
+		max, err := app.Search().Max(ctxBg, "weight")
+		require.NoError(t, err)
+		require.Equal(t, 248.0, max)
+
+		avg, err := app.Search().Avg(ctxBg, "weight")
+		require.NoError(t, err)
+		require.Equal(t, 124.0, avg)
+
+		// незаполненное поле без указателя не считается нулем
+		min, err = app.Search().Min(ctxBg, "stock")
+		require.NoError(t, err)
+		require.Equal(t, 200.0, min)
+		avg, err = app.Search().Avg(ctxBg, "stock")
+		require.NoError(t, err)
+		require.Equal(t, 224.5, avg)
+
+		_, err = app.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().From(1000)}}).Max(ctxBg, "price")
+		require.ErrorIs(t, err, ErrItemNotFound)
+		_, err = app.Search().Sum(ctxBg, "kind")
+		require.ErrorIs(t, err, ErrFieldType)
+		_, err = app.Search().Sum(ctxBg, "unknown")
+		require.ErrorIs(t, err, ErrUnknownField)
+	})
+
+	t.Run("group_by", func(t *testing.T) {
+		groups, err := app.Search().GroupBy(ctxBg, "kind")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"even": 125, "": 125}, groups)
+
+		groups, err = app.Search().GroupBy(ctxBg, "parent")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{fakeID(1000): 5, fakeID(1001): 5, "": 240}, groups)
+
+		_, err = app.Search().GroupBy(ctxBg, "cost")
+		require.ErrorIs(t, err, ErrFieldType)
+	})
+
+	t.Run("dynamic", func(t *testing.T) {
+		dynamic := NewDynamicApp(settings)
+		sum, err := dynamic.Search().Sum(ctxBg, "cost")
+		require.NoError(t, err)
+		require.Equal(t, float64(249*250/2), sum)
+
+		groups, err := dynamic.Search().GroupBy(ctxBg, "parent")
+		require.NoError(t, err)
+		require.Equal(t, 5, groups[fakeID(1001)])
+	})
+
+}
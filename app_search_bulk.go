@@ -0,0 +1,212 @@
+package e365_gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const bulkConcurrency = 4
+
+// BulkOptions - параметры массового изменения элементов (UpdateAll, SetStatusAll)
+type BulkOptions struct {
+	// Concurrency - кол-во одновременных запросов на изменение (по умолчанию 4)
+	Concurrency int
+	// DryRun - только определить, какие элементы будут изменены, без отправки изменений
+	DryRun bool
+}
+
+func (o BulkOptions) concurrency() int {
+	if o.Concurrency < 1 {
+		return bulkConcurrency
+	}
+	return o.Concurrency
+}
+
+// BulkResult - результат изменения одного элемента
+type BulkResult struct {
+	ID string
+	// Changed - элемент изменен (в режиме DryRun - был бы изменен)
+	Changed bool
+	Err     error
+}
+
+// BulkReport - отчет о массовом изменении элементов
+type BulkReport struct {
+	DryRun  bool
+	Matched int
+	Changed int
+	Failed  int
+	Items   []BulkResult
+}
+
+// Err возвращает ошибки изменения элементов, объединенные через errors.Join, или nil
+func (r BulkReport) Err() error {
+	errs := make([]error, 0, r.Failed)
+	for _, item := range r.Items {
+		if item.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.ID, item.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *BulkReport) add(res BulkResult) {
+	r.Items = append(r.Items, res)
+	if res.Changed {
+		r.Changed++
+	}
+	if res.Err != nil {
+		r.Failed++
+	}
+}
+
+// UpdateAll изменяет все элементы, найденные поиском: fn получает элемент и возвращает true, если его нужно сохранить.
+// Сначала собираются id всех найденных элементов (со стабильной сортировкой), затем элементы получаются
+// пачками по 100 и сохраняются через Update не более чем в opts.Concurrency запросов одновременно.
+// Поэтому изменения, после которых элемент перестает (или начинает) подходить под фильтр, не влияют на обход.
+// Ошибки изменения отдельных элементов попадают в отчет (см. BulkReport.Err), ошибка возвращается только если
+// не удалось выполнить поиск
+func (s searchInstance[T]) UpdateAll(ctx context.Context, fn func(item *T) bool, opts BulkOptions) (BulkReport, error) {
+
+	report := BulkReport{DryRun: opts.DryRun}
+	matched, err := s.snapshot(ctx, fieldID)
+	if err != nil {
+		return report, err
+	}
+	ids := make([]string, 0, len(matched))
+	for _, item := range matched {
+		ids = append(ids, commonOf(item).ID)
+	}
+	report.Matched = len(ids)
+
+	for start := 0; start < len(ids); start += idsChunkSize {
+		end := start + idsChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		found, err := s.app.fetchByIDs(ctx, chunk)
+		if err != nil {
+			return report, err
+		}
+
+		results := make([]BulkResult, len(chunk))
+		eg := errgroup.Group{}
+		eg.SetLimit(opts.concurrency())
+		for i, id := range chunk {
+			i, id := i, id
+			results[i].ID = id
+			item, ok := found[id]
+			if !ok {
+				results[i].Err = wrap(id, ErrItemNotFound)
+				continue
+			}
+			if !fn(&item) {
+				continue
+			}
+			results[i].Changed = true
+			if opts.DryRun {
+				continue
+			}
+			eg.Go(func() error {
+				if _, err := s.app.Update(ctx, id, item); err != nil {
+					results[i].Changed = false
+					results[i].Err = err
+				}
+				return nil
+			})
+		}
+		_ = eg.Wait()
+
+		for _, res := range results {
+			report.add(res)
+		}
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// SetStatusAll переводит все найденные элементы в статус code. Код статуса проверяется до начала изменений
+// (ErrUnknownStatus), элементы, уже находящиеся в этом статусе, пропускаются.
+// Как и UpdateAll, сначала собирает id найденных элементов, а затем меняет статусы не более чем
+// в opts.Concurrency запросов одновременно
+func (s searchInstance[T]) SetStatusAll(ctx context.Context, code string, opts BulkOptions) (BulkReport, error) {
+
+	report := BulkReport{DryRun: opts.DryRun}
+	status, err := NewStatusBook(*s.app, 0).ByCode(ctx, code)
+	if err != nil {
+		return report, err
+	}
+	target := status.Id
+
+	matched, err := s.snapshot(ctx, fieldID, fieldStatus)
+	if err != nil {
+		return report, err
+	}
+	report.Matched = len(matched)
+
+	results := make([]BulkResult, len(matched))
+	eg := errgroup.Group{}
+	eg.SetLimit(opts.concurrency())
+	for i, item := range matched {
+		c := commonOf(item)
+		i, id := i, c.ID
+		results[i].ID = id
+		if c.Status.Status == target {
+			continue
+		}
+		results[i].Changed = true
+		if opts.DryRun {
+			continue
+		}
+		eg.Go(func() error {
+			if ctx.Err() != nil {
+				results[i].Changed = false
+				results[i].Err = ctx.Err()
+				return nil
+			}
+			if _, err := s.app.SetStatus(ctx, id, code); err != nil {
+				results[i].Changed = false
+				results[i].Err = err
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	for _, res := range results {
+		report.add(res)
+	}
+	return report, ctx.Err()
+}
+
+// snapshot получает все найденные элементы без повторов (в порядке стабильной сортировки),
+// запрашивая у сервера только поля codes
+func (s searchInstance[T]) snapshot(ctx context.Context, codes ...string) ([]T, error) {
+	all := make([]T, 0)
+	seen := make(map[string]struct{})
+	s.selected = nil
+	s.includes = nil
+	err := s.Select(codes...).Size(maxPageSize).Iter().Each(ctx, func(items []T) error {
+		for _, item := range items {
+			id := commonOf(item).ID
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			all = append(all, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
@@ -0,0 +1,80 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulk(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 300)
+	for i := 0; i < 300; i++ {
+		item := fakeItem(i, start.Add(time.Duration(i)*time.Minute), float64(i))
+		item["__status"] = map[string]interface{}{"order": float64(0), "status": float64(1 + i%3)}
+		items = append(items, item)
+	}
+	fa, settings := newFakeApp(t, items)
+	fa.StatusInfo = testStatusInfo
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+	cheap := goods.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().To(149)}})
+
+	t.Run("update_dry_run", func(t *testing.T) {
+		report, err := cheap.UpdateAll(ctxBg, func(p *Product) bool {
+			return p.Price%2 == 0
+		}, BulkOptions{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, 150, report.Matched)
+		require.Equal(t, 75, report.Changed)
+		require.Len(t, report.Items, 150)
+		require.Zero(t, fa.Hits["/update"])
+	})
+
+	t.Run("update_changes_membership", func(t *testing.T) {
+		report, err := cheap.UpdateAll(ctxBg, func(p *Product) bool {
+			p.Price += 1000
+			return true
+		}, BulkOptions{Concurrency: 8})
+		require.NoError(t, err)
+		require.NoError(t, report.Err())
+		require.Equal(t, 150, report.Matched)
+		require.Equal(t, 150, report.Changed)
+		require.Equal(t, 150, fa.Hits["/update"])
+		require.Equal(t, fakeID(0), report.Items[0].ID)
+
+		count, err := cheap.Count(ctxBg)
+		require.NoError(t, err)
+		require.Zero(t, count)
+		item, err := goods.GetByID(ctxBg, fakeID(10))
+		require.NoError(t, err)
+		require.Equal(t, 1010, item.Price)
+	})
+
+	t.Run("set_status", func(t *testing.T) {
+		expensive := goods.Search().Where(SearchFilter{Fields: Fields{"price": Field.Number().From(1000)}})
+
+		report, err := expensive.SetStatusAll(ctxBg, "closed", BulkOptions{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, 150, report.Matched)
+		require.Equal(t, 100, report.Changed)
+		require.Zero(t, fa.Hits["/set-status"])
+
+		report, err = expensive.SetStatusAll(ctxBg, "closed", BulkOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 100, report.Changed)
+		require.Equal(t, 100, fa.Hits["/set-status"])
+
+		counts, err := expensive.CountByStatus(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"new": 0, "in_work": 0, "closed": 150}, counts)
+
+		_, err = expensive.SetStatusAll(ctxBg, "archived", BulkOptions{})
+		require.ErrorIs(t, err, ErrUnknownStatus)
+		require.Contains(t, err.Error(), `"archived" (available: new, in_work, closed)`)
+	})
+
+}
@@ -8,12 +8,33 @@ import (
 
 // filter общий набор фильтров
 type filter struct {
-	From   int  `json:"from"`
-	Size   int  `json:"size"`
-	Active bool `json:"active"`
+	From       int             `json:"from"`
+	Size       int             `json:"size"`
+	Active     bool            `json:"active"`
+	Projection map[string]bool `json:"fields,omitempty"`
+	Text       string          `json:"searchString,omitempty"`
 	SearchFilter
 }
 
+// MarshalJSON объединяет типизированный фильтр Fields и составное условие Query в одно выражение
+func (f filter) MarshalJSON() ([]byte, error) {
+	type plain filter
+	var expr interface{} = f.Fields
+	if f.Query != nil {
+		expr = f.Query
+		if len(f.Fields) != 0 {
+			expr = And(f.Fields, f.Query)
+		}
+	}
+	return json.Marshal(struct {
+		plain
+		Filter interface{} `json:"filter"`
+	}{
+		plain:  plain(f),
+		Filter: expr,
+	})
+}
+
 // SearchFilter - набор фильтров для выполнения поиска
 type SearchFilter struct {
 	Fields          Fields           `json:"filter"`
@@ -21,6 +42,8 @@ type SearchFilter struct {
 	SortExpressions []SortExpression `json:"sortExpressions,omitempty"`
 	AtStatus        []string         `json:"statusCode,omitempty"`
 	StatusGroupId   string           `json:"statusGroupId,omitempty"`
+	// Query - составное условие (And, Or, Not, Eq, Like, In, Link и т.д.), применяется вместе с Fields
+	Query Condition `json:"-"`
 }
 
 type SortExpression struct {
@@ -48,7 +71,7 @@ func (f Fields) MarshalJSON() ([]byte, error) {
 		sb.WriteString(k)
 		sb.WriteRune('"')
 		sb.WriteRune(':')
-		if bts, err = json.Marshal(v); err != nil {
+		if bts, err = json.Marshal(filterValue(v)); err != nil {
 			return nil, err
 		}
 		sb.Write(bts)
@@ -61,6 +84,19 @@ func (f Fields) MarshalJSON() ([]byte, error) {
 	return []byte(sb.String()), nil
 }
 
+// filterValuer - значение поля, которое в фильтрах представляется иначе, чем в элементе (например, типы из пакета types)
+type filterValuer interface {
+	FilterValue() interface{}
+}
+
+// filterValue возвращает представление значения для фильтра
+func filterValue(v interface{}) interface{} {
+	if fv, ok := v.(filterValuer); ok {
+		return fv.FilterValue()
+	}
+	return v
+}
+
 type fielder interface {
 	Category(code string) string
 	App(id string) [1]string
@@ -0,0 +1,119 @@
+package e365_gateway
+
+import (
+	"context"
+	"errors"
+)
+
+const maxPageSize = 100
+
+// Iterator - постраничный обход результатов поиска
+type Iterator[T interface{}] struct {
+	search   searchInstance[T]
+	resolved bool
+	// merge - слияние частей поиска с подзапросом, разбитым на пачки (см. resolve)
+	merge *partsMerge[T]
+	size  int
+	from  int
+}
+
+// Iter создает итератор по результатам поиска. Размер страницы задается через Size (не более 100),
+// обход начинается с From. Если сортировка не задана, элементы сортируются по __id.
+func (s searchInstance[T]) Iter() *Iterator[T] {
+	if s.size < 1 || s.size > maxPageSize {
+		s.size = maxPageSize
+	}
+	return &Iterator[T]{
+		search: s.stable(),
+		size:   s.size,
+		from:   s.from,
+	}
+}
+
+// Next получает следующую страницу. Когда элементы закончились, возвращает ErrNoMoreItems
+func (it *Iterator[T]) Next(ctx context.Context) ([]T, error) {
+	if err := it.search.check(); err != nil {
+		return nil, err
+	}
+
+	if !it.resolved {
+		parts, err := it.search.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		it.resolved = true
+		if len(parts) > 1 {
+			// элементы разных пачек подзапроса могут повторяться, поэтому From применяется к объединенному результату
+			it.merge = newPartsMerge(parts)
+			for ; it.from > 0; it.from-- {
+				_, ok, err := it.merge.next(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, ErrNoMoreItems
+				}
+			}
+		} else {
+			it.search = parts[0]
+		}
+	}
+
+	if it.merge != nil {
+		items := make([]T, 0, it.size)
+		for len(items) < it.size {
+			item, ok, err := it.merge.next(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			items = append(items, item)
+		}
+		if len(items) == 0 {
+			return nil, ErrNoMoreItems
+		}
+		if err := it.search.applyIncludes(ctx, items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	if it.from < 0 {
+		return nil, ErrNoMoreItems
+	}
+	s := it.search
+	items, _, err := s.app.find(ctx, s.newFilter(it.from, s.size))
+	if err != nil {
+		return nil, err
+	}
+	if len(items) < s.size {
+		it.from = -1
+	} else {
+		it.from += len(items)
+	}
+	if len(items) == 0 {
+		return nil, ErrNoMoreItems
+	}
+	if err = s.applyIncludes(ctx, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Each обходит все страницы итератора и передает элементы в fn. Ошибка из fn прерывает обход
+func (it *Iterator[T]) Each(ctx context.Context, fn func(items []T) error) error {
+	for {
+		items, err := it.Next(ctx)
+		if errors.Is(err, ErrNoMoreItems) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(items); err != nil {
+			return err
+		}
+	}
+}
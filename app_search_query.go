@@ -0,0 +1,184 @@
+package e365_gateway
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	opAnd   = "and"
+	opOr    = "or"
+	opNot   = "not"
+	opEq    = "eq"
+	opLike  = "like"
+	opIn    = "in"
+	opLink  = "link"
+	opTable = "table"
+)
+
+// Condition - условие составного фильтра. Условия собираются функциями And, Or, Not, Eq, Like, In, Link и т.д.
+// и передаются в поиск через SearchFilter.Query или searchInstance.Match.
+// Fields тоже является условием, поэтому типизированный фильтр можно вкладывать в And/Or.
+type Condition interface {
+	json.Marshaler
+	// fields возвращает коды полей, которые участвуют в условии
+	fields() []string
+}
+
+// fields возвращает коды полей типизированного фильтра
+func (f Fields) fields() []string {
+	codes := make([]string, 0, len(f))
+	for k := range f {
+		codes = append(codes, k)
+	}
+	return codes
+}
+
+// group - логическое объединение нескольких условий (and, or)
+type group struct {
+	op    string
+	items []Condition
+}
+
+func (g group) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]Condition{g.op: g.items})
+}
+
+func (g group) fields() []string {
+	codes := make([]string, 0, len(g.items))
+	for _, c := range g.items {
+		codes = append(codes, c.fields()...)
+	}
+	return codes
+}
+
+// negation - отрицание условия
+type negation struct {
+	item Condition
+}
+
+func (n negation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]Condition{opNot: n.item})
+}
+
+func (n negation) fields() []string {
+	return n.item.fields()
+}
+
+// comparison - сравнение поля с константой или списком значений (eq, like, in, link)
+type comparison struct {
+	op      string
+	field   string
+	operand map[string]interface{}
+}
+
+func (c comparison) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][2]interface{}{
+		c.op: {map[string]string{"field": c.field}, c.operand},
+	})
+}
+
+func (c comparison) fields() []string {
+	return []string{c.field}
+}
+
+// rowCondition - условие на строки поля типа "Таблица"
+type rowCondition struct {
+	table string
+	row   Condition
+}
+
+func (rc rowCondition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][2]interface{}{
+		opTable: {map[string]string{"field": rc.table}, rc.row},
+	})
+}
+
+func (rc rowCondition) fields() []string {
+	return []string{rc.table}
+}
+
+// And объединяет условия через логическое И
+func And(conds ...Condition) Condition {
+	return group{op: opAnd, items: conds}
+}
+
+// Or объединяет условия через логическое ИЛИ
+func Or(conds ...Condition) Condition {
+	return group{op: opOr, items: conds}
+}
+
+// Not инвертирует условие
+func Not(cond Condition) Condition {
+	return negation{item: cond}
+}
+
+// Eq - точное равенство поля значению (строки сравниваются целиком, подходит и для полей типа "Да/Нет").
+// Значения из пакета types передаются в представлении для фильтров (например, Category - кодом варианта)
+func Eq(field string, value interface{}) Condition {
+	return comparison{op: opEq, field: field, operand: map[string]interface{}{"const": filterValue(value)}}
+}
+
+// Bool - условие для полей типа "Да/Нет"
+func Bool(field string, value bool) Condition {
+	return Eq(field, value)
+}
+
+// Like - сравнение строкового поля с шаблоном, где % заменяет любую последовательность символов
+func Like(field, pattern string) Condition {
+	return comparison{op: opLike, field: field, operand: map[string]interface{}{"const": pattern}}
+}
+
+// Contains - поиск подстроки в строковом поле
+func Contains(field, substr string) Condition {
+	return Like(field, "%"+strings.Trim(substr, "%")+"%")
+}
+
+// In - значение поля входит в список (например, коды для полей типа "Категория")
+func In(field string, values ...interface{}) Condition {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		list = append(list, filterValue(v))
+	}
+	return comparison{op: opIn, field: field, operand: map[string]interface{}{"list": list}}
+}
+
+// Link - поле типа "Приложение" ссылается хотя бы на один из элементов с переданными id
+func Link(field string, ids ...string) Condition {
+	if ids == nil {
+		ids = []string{}
+	}
+	return comparison{op: opLink, field: field, operand: map[string]interface{}{"list": ids}}
+}
+
+// IsNull - поле не заполнено
+func IsNull(field string) Condition {
+	return Eq(field, nil)
+}
+
+// NotNull - поле заполнено
+func NotNull(field string) Condition {
+	return Not(IsNull(field))
+}
+
+// AnyRow - хотя бы одна строка поля типа "Таблица" удовлетворяет условию row
+// (в row указываются коды колонок таблицы)
+func AnyRow(table string, row Condition) Condition {
+	return rowCondition{table: table, row: row}
+}
+
+// Match добавляет к поиску условие составного фильтра (через логическое И с уже заданными условиями)
+func (s searchInstance[T]) Match(conds ...Condition) searchInstance[T] {
+	if len(conds) == 0 {
+		return s
+	}
+	if s.search.Query != nil {
+		conds = append([]Condition{s.search.Query}, conds...)
+	}
+	if len(conds) == 1 {
+		s.search.Query = conds[0]
+		return s
+	}
+	s.search.Query = And(conds...)
+	return s
+}
@@ -0,0 +1,141 @@
+package e365_gateway
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQuery(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		filter   SearchFilter
+		expected string
+	}{
+		{
+			name:     "fields_only",
+			filter:   SearchFilter{Fields: Fields{"isPoAktsii": true}},
+			expected: `{"tf":{"isPoAktsii":true}}`,
+		},
+		{
+			name:     "empty",
+			filter:   SearchFilter{},
+			expected: `{"tf":{}}`,
+		},
+		{
+			name:     "query_only",
+			filter:   SearchFilter{Query: Eq("__name", "theMostExpensiveProduct")},
+			expected: `{"eq":[{"field":"__name"},{"const":"theMostExpensiveProduct"}]}`,
+		},
+		{
+			name: "fields_and_query",
+			filter: SearchFilter{
+				Fields: Fields{"price": Field.Number().Equal(2000)},
+				Query:  Or(Contains("__name", "acme"), IsNull("categ")),
+			},
+			expected: `{"and":[
+				{"tf":{"price":{"min":2000,"max":2000}}},
+				{"or":[
+					{"like":[{"field":"__name"},{"const":"%acme%"}]},
+					{"eq":[{"field":"categ"},{"const":null}]}
+				]}
+			]}`,
+		},
+		{
+			name: "nested",
+			filter: SearchFilter{
+				Query: And(
+					Not(In("categ", "one", "two")),
+					Link("appField", "72f60b1a-168e-412f-8cfd-e119c28e99b7"),
+					NotNull("price"),
+					Bool("isPoAktsii", false),
+					AnyRow("goods", Eq("qty", 1)),
+				),
+			},
+			expected: `{"and":[
+				{"not":{"in":[{"field":"categ"},{"list":["one","two"]}]}},
+				{"link":[{"field":"appField"},{"list":["72f60b1a-168e-412f-8cfd-e119c28e99b7"]}]},
+				{"not":{"eq":[{"field":"price"},{"const":null}]}},
+				{"eq":[{"field":"isPoAktsii"},{"const":false}]},
+				{"table":[{"field":"goods"},{"eq":[{"field":"qty"},{"const":1}]}]}
+			]}`,
+		},
+		{
+			name: "types_values",
+			filter: SearchFilter{
+				Fields: Fields{"categ": types.Category{Code: "one", Name: "Один"}},
+				Query: And(
+					In("tags", types.Category{Code: "a"}, types.Category{Code: "b"}),
+					Eq("birthday", types.Date{Year: 1990, Month: time.May, Day: 17}),
+				),
+			},
+			expected: `{"and":[
+				{"tf":{"categ":"one"}},
+				{"and":[
+					{"in":[{"field":"tags"},{"list":["a","b"]}]},
+					{"eq":[{"field":"birthday"},{"const":"1990-05-17"}]}
+				]}
+			]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bts, err := json.Marshal(filter{Size: 10, Active: true, SearchFilter: tc.filter})
+			require.NoError(t, err)
+
+			var body map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(bts, &body))
+			require.JSONEq(t, tc.expected, string(body["filter"]))
+			require.JSONEq(t, "10", string(body["size"]))
+			require.JSONEq(t, "true", string(body["active"]))
+			require.NotContains(t, body, "Query")
+		})
+	}
+
+	t.Run("match_combines_conditions", func(t *testing.T) {
+		s := App[Product]{}.Search().
+			Match(Eq("__name", "a")).
+			Match(Eq("price", 1), Eq("categ", "one"))
+		require.ElementsMatch(t, []string{"__name", "price", "categ"}, s.search.Query.fields())
+	})
+
+	t.Run("where_keeps_match", func(t *testing.T) {
+		s := App[Product]{}.Search().
+			Match(Eq("__name", "a")).
+			Where(SearchFilter{Fields: Fields{"isPoAktsii": true}, Query: Eq("price", 1)})
+		bts, err := json.Marshal(s.newFilter(0, 10))
+		require.NoError(t, err)
+		var body map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(bts, &body))
+		require.JSONEq(t, `{"and":[
+			{"tf":{"isPoAktsii":true}},
+			{"and":[
+				{"eq":[{"field":"price"},{"const":1}]},
+				{"eq":[{"field":"__name"},{"const":"a"}]}
+			]}
+		]}`, string(body["filter"]))
+	})
+
+	t.Run("orderby_then_where", func(t *testing.T) {
+		s := App[Product]{}.Search().OrderByDesc("price").Where(SearchFilter{Fields: Fields{"isPoAktsii": true}})
+		require.Equal(t, []SortExpression{{Ascending: false, Field: "price"}}, s.search.SortExpressions)
+
+		s = s.Where(SearchFilter{SortExpressions: []SortExpression{{Ascending: true, Field: "__name"}}})
+		require.Equal(t, []SortExpression{{Ascending: true, Field: "__name"}}, s.search.SortExpressions)
+	})
+
+	t.Run("where_twice", func(t *testing.T) {
+		s := App[Product]{}.Search().
+			Where(SearchFilter{Fields: Fields{"isPoAktsii": true, "price": Field.Number().From(10)}, AtStatus: []string{"new"}}).
+			Where(SearchFilter{Fields: Fields{"price": Field.Number().To(20)}, Query: Eq("__name", "a")})
+		require.Equal(t, Fields{"isPoAktsii": true, "price": appNumberFilter{"min": 10, "max": 20}}, s.search.Fields)
+		require.Equal(t, []string{"new"}, s.search.AtStatus)
+		require.Equal(t, []string{"__name"}, s.search.Query.fields())
+	})
+
+}
@@ -0,0 +1,348 @@
+package e365_gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	scanPageSize       = 100
+	scanDefaultWindows = 10
+
+	fieldID        = "__id"
+	fieldCreatedAt = "__createdAt"
+)
+
+// ScanOptions - параметры обхода для Scan.
+// Если Field не задан, используется keyset-пагинация по __createdAt и __id (начиная с DateFrom, если он задан).
+// Если Field задан, диапазон поля разбивается на Windows окон, которые обходятся параллельно:
+// для полей типа "Дата" нужно задать DateFrom и DateTo, для полей типа "Число" - NumberFrom и NumberTo.
+// Внутри каждого окна используется keyset-пагинация по __createdAt и __id.
+type ScanOptions struct {
+	Field string
+
+	DateFrom time.Time
+	DateTo   time.Time
+
+	NumberFrom float64
+	NumberTo   float64
+
+	// Windows - кол-во окон, на которое разбивается диапазон (по умолчанию 10)
+	Windows int
+	// GoroutineLimit - кол-во одновременно обрабатываемых окон (по умолчанию 1)
+	GoroutineLimit int
+}
+
+// windows разбивает диапазон поля на фильтры для Field.DateTime() или Field.Number()
+func (opts ScanOptions) windows() ([]interface{}, error) {
+	n := opts.Windows
+	if n < 1 {
+		n = scanDefaultWindows
+	}
+
+	windows := make([]interface{}, 0, n)
+	switch {
+	case !opts.DateFrom.IsZero() && opts.DateTo.After(opts.DateFrom):
+		step := opts.DateTo.Sub(opts.DateFrom) / time.Duration(n)
+		if step < time.Second {
+			step = time.Second
+		}
+		for start := opts.DateFrom; start.Before(opts.DateTo); start = start.Add(step) {
+			end := start.Add(step)
+			if end.After(opts.DateTo) {
+				end = opts.DateTo
+			}
+			windows = append(windows, Field.DateTime().From(start).To(end))
+		}
+	case opts.NumberTo > opts.NumberFrom:
+		step := (opts.NumberTo - opts.NumberFrom) / float64(n)
+		for i := 0; i < n; i++ {
+			end := opts.NumberFrom + step*float64(i+1)
+			if i == n-1 {
+				end = opts.NumberTo
+			}
+			windows = append(windows, Field.Number().From(opts.NumberFrom+step*float64(i)).To(end))
+		}
+	default:
+		return nil, wrap(opts.Field, ErrInvalidScanRange)
+	}
+
+	return windows, nil
+}
+
+// Scan получает все элементы по переданному фильтру, не упираясь в ограничения сервера на From.
+// Каждый элемент возвращается ровно один раз. Сортировка из фильтра не учитывается.
+func (s searchInstance[T]) Scan(ctx context.Context, opts ScanOptions) ([]T, error) {
+	all := make([]T, 0)
+	err := s.ScanEach(ctx, opts, func(items []T) error {
+		all = append(all, items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// ScanEach работает как Scan, но вместо накопления результата передает в fn каждую полученную страницу.
+// Вызовы fn не выполняются одновременно; ошибка из fn прерывает обход.
+func (s searchInstance[T]) ScanEach(ctx context.Context, opts ScanOptions, fn func(items []T) error) error {
+
+	if err := s.check(); err != nil {
+		return err
+	}
+	parts, err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if len(parts) > 1 {
+		return scanParts(ctx, parts, opts, fn)
+	}
+	s = parts[0]
+	if len(s.includes) > 0 {
+		next := fn
+		fn = func(items []T) error {
+			if err := s.applyIncludes(ctx, items); err != nil {
+				return err
+			}
+			return next(items)
+		}
+	}
+
+	if opts.Field == "" {
+		return s.scanKeyset(ctx, fieldCreatedAt, opts.DateFrom, fn)
+	}
+
+	windows, err := opts.windows()
+	if err != nil {
+		return err
+	}
+
+	goroutineLimit := opts.GoroutineLimit
+	if goroutineLimit < 1 {
+		goroutineLimit = 1
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(goroutineLimit)
+
+	// границы соседних окон пересекаются, поэтому элементы дедуплицируются по __id
+	seen := make(map[string]struct{})
+	mu := sync.Mutex{}
+	for _, window := range windows {
+		ws := s.withField(opts.Field, window)
+		eg.Go(func() error {
+			// внутри окна элементы обходятся по __createdAt, т.к. окно может быть больше ограничения сервера на From
+			return ws.scanKeyset(egCtx, fieldCreatedAt, time.Time{}, func(items []T) error {
+				mu.Lock()
+				defer mu.Unlock()
+				fresh := make([]T, 0, len(items))
+				for _, item := range items {
+					id := commonOf(item).ID
+					if id == "" {
+						return ErrScanUnsupported
+					}
+					if _, ok := seen[id]; ok {
+						continue
+					}
+					seen[id] = struct{}{}
+					fresh = append(fresh, item)
+				}
+				if len(fresh) == 0 {
+					return nil
+				}
+				return fn(fresh)
+			})
+		})
+	}
+
+	return eg.Wait()
+}
+
+// scanParts обходит части поиска с подзапросом (см. resolve), пропуская элементы, попавшие в несколько пачек
+func scanParts[T interface{}](ctx context.Context, parts []searchInstance[T], opts ScanOptions, fn func(items []T) error) error {
+	seen := make(map[string]struct{})
+	for _, part := range parts {
+		err := part.ScanEach(ctx, opts, func(items []T) error {
+			fresh := make([]T, 0, len(items))
+			for _, item := range items {
+				id := commonOf(item).ID
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				fresh = append(fresh, item)
+			}
+			if len(fresh) == 0 {
+				return nil
+			}
+			return fn(fresh)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanKeyset обходит элементы по возрастанию поля даты field (__createdAt или __updatedAt), каждый раз начиная
+// с даты последнего полученного элемента. Фильтр по дате работает с точностью до секунды, поэтому уже полученные элементы
+// с граничной секундой запоминаются и пропускаются.
+func (s searchInstance[T]) scanKeyset(ctx context.Context, field string, from time.Time, fn func(items []T) error) error {
+
+	if len(s.selected) > 0 {
+		s = s.Select(field)
+	}
+	sorted := s.withSort(field, fieldID)
+	cursor := from.UTC().Truncate(time.Second)
+	offset := 0
+	seen := make(map[string]struct{})
+
+	for {
+		page := sorted
+		if !cursor.IsZero() {
+			page = sorted.withField(field, Field.DateTime().From(cursor))
+		}
+
+		items, _, err := page.app.find(ctx, page.newFilter(offset, scanPageSize))
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		fresh := make([]T, 0, len(items))
+		keys := make([]time.Time, 0, len(items))
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			c := commonOf(item)
+			key := c.CreatedAt
+			if field == fieldUpdatedAt {
+				key = c.UpdatedAt
+			}
+			if c.ID == "" || key.IsZero() {
+				return ErrScanUnsupported
+			}
+			keys = append(keys, key.UTC().Truncate(time.Second))
+			ids = append(ids, c.ID)
+			if _, ok := seen[c.ID]; ok {
+				continue
+			}
+			fresh = append(fresh, item)
+		}
+
+		if len(fresh) > 0 {
+			if err = fn(fresh); err != nil {
+				return err
+			}
+		}
+		if len(items) < scanPageSize {
+			return nil
+		}
+
+		last := keys[len(keys)-1]
+		if last.After(cursor) {
+			// сдвигаем курсор, смещение больше не нужно
+			cursor = last
+			offset = 0
+			seen = make(map[string]struct{})
+		} else {
+			// вся страница пришлась на одну секунду - идем дальше по смещению
+			offset += len(items)
+		}
+		for i, key := range keys {
+			if key.Equal(cursor) {
+				seen[ids[i]] = struct{}{}
+			}
+		}
+	}
+}
+
+// withField возвращает копию поиска с дополнительным фильтром по полю code. Если в поиске уже есть фильтр
+// по этому полю, границы диапазонов пересекаются, а фильтр другого вида переносится в Match
+func (s searchInstance[T]) withField(code string, value interface{}) searchInstance[T] {
+	fields := make(Fields, len(s.search.Fields)+1)
+	for k, v := range s.search.Fields {
+		fields[k] = v
+	}
+	if prev, ok := fields[code]; ok {
+		if merged, ok := intersectRange(prev, value); ok {
+			value = merged
+		} else {
+			s = s.Match(Fields{code: prev})
+		}
+	}
+	fields[code] = value
+	s.search.Fields = fields
+	return s
+}
+
+// intersectRange пересекает диапазоны Field.DateTime() или Field.Number()
+func intersectRange(a, b interface{}) (interface{}, bool) {
+	switch a := a.(type) {
+	case appDateFilter:
+		b, ok := b.(appDateFilter)
+		if !ok {
+			return nil, false
+		}
+		res := appDateFilter{}
+		for k, v := range a {
+			res[k] = v
+		}
+		for k, v := range b {
+			cur, ok := res[k]
+			if !ok {
+				res[k] = v
+				continue
+			}
+			ct, cerr := parseFilterDate(cur)
+			vt, verr := parseFilterDate(v)
+			if cerr != nil || verr != nil {
+				return nil, false
+			}
+			if k == "min" && vt.After(ct) || k == "max" && vt.Before(ct) {
+				res[k] = v
+			}
+		}
+		return res, true
+	case appNumberFilter:
+		b, ok := b.(appNumberFilter)
+		if !ok {
+			return nil, false
+		}
+		res := appNumberFilter{}
+		for k, v := range a {
+			res[k] = v
+		}
+		for k, v := range b {
+			cur, ok := res[k]
+			if !ok || k == "min" && v > cur || k == "max" && v < cur {
+				res[k] = v
+			}
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// parseFilterDate разбирает границу Field.DateTime() (RFC3339 или дата без времени)
+func parseFilterDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// withSort возвращает копию поиска с сортировкой по возрастанию переданных полей
+func (s searchInstance[T]) withSort(codes ...string) searchInstance[T] {
+	sort := make([]SortExpression, 0, len(codes))
+	for _, code := range codes {
+		sort = append(sort, SortExpression{Ascending: true, Field: code})
+	}
+	s.search.SortExpressions = sort
+	return s
+}
@@ -0,0 +1,123 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchScan(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 350)
+	for i := 0; i < 350; i++ {
+		createdAt := start.Add(time.Duration(i) * time.Minute)
+		if i >= 100 && i < 270 {
+			// больше страницы элементов с одной и той же секундой создания
+			createdAt = start.Add(time.Hour*24 + time.Duration(i)*time.Millisecond)
+		}
+		items = append(items, fakeItem(i, createdAt, float64(i%50)))
+	}
+	items[5]["__deletedAt"] = start.Format(time.RFC3339)
+
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	requireUnique := func(t *testing.T, got []Product, want int) {
+		ids := make(map[string]struct{}, len(got))
+		for _, item := range got {
+			ids[item.ID] = struct{}{}
+		}
+		require.Len(t, got, want)
+		require.Len(t, ids, want)
+	}
+
+	t.Run("keyset", func(t *testing.T) {
+		got, err := goods.Search().Scan(ctxBg, ScanOptions{})
+		require.NoError(t, err)
+		requireUnique(t, got, 349)
+	})
+
+	t.Run("keyset_include_deleted", func(t *testing.T) {
+		got, err := goods.Search().IncludeDeleted().Scan(ctxBg, ScanOptions{})
+		require.NoError(t, err)
+		requireUnique(t, got, 350)
+	})
+
+	t.Run("keyset_from_date", func(t *testing.T) {
+		got, err := goods.Search().Scan(ctxBg, ScanOptions{DateFrom: start.Add(time.Hour * 24)})
+		require.NoError(t, err)
+		requireUnique(t, got, 170)
+	})
+
+	t.Run("keyset_with_date_filter", func(t *testing.T) {
+		// фильтр по __createdAt пересекается с курсором, а не заменяется им
+		got, err := goods.Search().
+			Where(SearchFilter{Fields: Fields{"__createdAt": Field.DateTime().To(start.Add(50 * time.Minute))}}).
+			Scan(ctxBg, ScanOptions{DateFrom: start.Add(10 * time.Minute)})
+		require.NoError(t, err)
+		requireUnique(t, got, 41)
+	})
+
+	t.Run("number_windows", func(t *testing.T) {
+		got, err := goods.Search().Scan(ctxBg, ScanOptions{
+			Field:          "price",
+			NumberFrom:     0,
+			NumberTo:       49,
+			Windows:        7,
+			GoroutineLimit: 3,
+		})
+		require.NoError(t, err)
+		requireUnique(t, got, 349)
+	})
+
+	t.Run("date_windows_with_filter", func(t *testing.T) {
+		got, err := goods.Search().
+			Where(SearchFilter{Fields: Fields{"price": Field.Number().To(9)}}).
+			Scan(ctxBg, ScanOptions{
+				Field:          "__createdAt",
+				DateFrom:       start,
+				DateTo:         start.Add(time.Hour * 48),
+				GoroutineLimit: 4,
+			})
+		require.NoError(t, err)
+		requireUnique(t, got, 69)
+	})
+
+	t.Run("number_windows_with_same_field", func(t *testing.T) {
+		got, err := goods.Search().
+			Where(SearchFilter{Fields: Fields{"price": Field.Number().From(10).To(19)}}).
+			Scan(ctxBg, ScanOptions{Field: "price", NumberFrom: 0, NumberTo: 49, Windows: 5})
+		require.NoError(t, err)
+		requireUnique(t, got, 70)
+	})
+
+	t.Run("window_larger_than_page", func(t *testing.T) {
+		// одно окно со всеми элементами обходится без больших смещений
+		bodies := len(fa.Bodies)
+		got, err := goods.Search().Scan(ctxBg, ScanOptions{Field: "price", NumberFrom: 0, NumberTo: 49, Windows: 1})
+		require.NoError(t, err)
+		requireUnique(t, got, 349)
+		for _, body := range fa.Bodies[bodies:] {
+			require.LessOrEqual(t, body["from"], float64(scanPageSize))
+		}
+	})
+
+	t.Run("invalid_range", func(t *testing.T) {
+		_, err := goods.Search().Scan(ctxBg, ScanOptions{Field: "price"})
+		require.ErrorIs(t, err, ErrInvalidScanRange)
+	})
+
+	t.Run("each_stops_on_error", func(t *testing.T) {
+		lists := fa.Lists
+		err := goods.Search().ScanEach(ctxBg, ScanOptions{}, func(items []Product) error {
+			return ErrNoMoreItems
+		})
+		require.ErrorIs(t, err, ErrNoMoreItems)
+		require.Equal(t, lists+1, fa.Lists)
+	})
+
+}
@@ -0,0 +1,101 @@
+package e365_gateway
+
+import (
+	"context"
+	"reflect"
+)
+
+// projection собирает набор полей, которые должен вернуть сервер. Пустой набор означает все поля
+func projection(codes []string) map[string]bool {
+	if len(codes) == 0 {
+		return nil
+	}
+	p := make(map[string]bool, len(codes)+2)
+	p["*"] = false
+	p[fieldID] = true
+	for _, code := range codes {
+		p[code] = true
+	}
+	return p
+}
+
+// Select ограничивает набор полей, которые сервер вернет для каждого элемента (__id возвращается всегда).
+// Остальные поля T останутся пустыми. Если сервер не поддерживает выборку полей, вернутся все поля,
+// в таком случае облегчить декодирование поможет SearchAs
+func (s searchInstance[T]) Select(codes ...string) searchInstance[T] {
+	selected := make([]string, 0, len(s.selected)+len(codes))
+	selected = append(selected, s.selected...)
+	s.selected = append(selected, codes...)
+	return s
+}
+
+// SearchAs переносит параметры поиска в поиск с облегченным типом P (проекцией T).
+// Элементы декодируются сразу в P, а у сервера запрашиваются только поля P (если Select не был вызван ранее)
+func SearchAs[P, T interface{}](s searchInstance[T]) searchInstance[P] {
+	selected := s.selected
+	if len(selected) == 0 {
+		fields := itemFieldsOf(reflect.TypeOf((*P)(nil)).Elem())
+		selected = make([]string, 0, len(fields))
+		for _, f := range fields {
+			selected = append(selected, f.code)
+		}
+	}
+	app := appAs[P](*s.app)
+	return searchInstance[P]{
+		search:         s.search,
+		includeDeleted: s.includeDeleted,
+		strict:         s.strict,
+		selected:       selected,
+		text:           s.text,
+		includes:       s.includes,
+		size:           s.size,
+		from:           s.from,
+		app:            &app,
+	}
+}
+
+// appAs создает адаптер к тому же приложению, но с контекстом P
+func appAs[P, T interface{}](app App[T]) App[P] {
+	return App[P]{
+		url:       app.url,
+		namespace: app.namespace,
+		code:      app.code,
+		stand:     app.stand,
+		client:    app.client,
+		header:    app.header,
+		cache:     app.cache,
+		method:    app.method,
+	}
+}
+
+// GetOption - дополнительный параметр для GetByID
+type GetOption func(o *getOptions)
+
+type getOptions struct {
+	fields []string
+}
+
+// WithFields ограничивает набор полей, которые сервер вернет для элемента (см. Select)
+func WithFields(codes ...string) GetOption {
+	return func(o *getOptions) {
+		o.fields = append(o.fields, codes...)
+	}
+}
+
+// getSelected получает элемент с ограниченным набором полей через /list
+func (app App[T]) getSelected(ctx context.Context, id string, fields []string) (T, error) {
+	var nilT T
+	items, err := app.Search().
+		IncludeDeleted().
+		Where(SearchFilter{IDs: []string{id}}).
+		Select(fields...).
+		Size(1).
+		All(ctx)
+	if err != nil {
+		return nilT, err
+	}
+	if len(items) == 0 {
+		return nilT, wrap(id, ErrItemNotFound)
+	}
+	return items[0], nil
+}
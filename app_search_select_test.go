@@ -0,0 +1,64 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSelect(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 20)
+	for i := 0; i < 20; i++ {
+		items = append(items, fakeItem(i, start.Add(time.Duration(i)*time.Second), float64(i)))
+	}
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	t.Run("select", func(t *testing.T) {
+		found, err := goods.Search().Select("price").OrderByDesc("price").First(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, 19, found.Price)
+		require.Equal(t, fakeID(19), found.ID)
+		require.Empty(t, found.Name)
+
+		last := fa.Bodies[len(fa.Bodies)-1]
+		require.Equal(t, map[string]interface{}{"*": false, "__id": true, "price": true}, last["fields"])
+	})
+
+	t.Run("get_by_id_with_fields", func(t *testing.T) {
+		item, err := goods.GetByID(ctxBg, fakeID(3), WithFields("__name"))
+		require.NoError(t, err)
+		require.Equal(t, "item", item.Name)
+		require.Zero(t, item.Price)
+
+		_, err = goods.GetByID(ctxBg, fakeID(42), WithFields("__name"))
+		require.ErrorIs(t, err, ErrItemNotFound)
+	})
+
+	t.Run("search_as", func(t *testing.T) {
+		type PriceOnly struct {
+			ID    string  `json:"__id"`
+			Price float64 `json:"price"`
+		}
+		prices, err := SearchAs[PriceOnly](goods.Search().Where(SearchFilter{
+			Fields: Fields{"price": Field.Number().From(15)},
+		})).All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, prices, 5)
+
+		last := fa.Bodies[len(fa.Bodies)-1]
+		require.Equal(t, map[string]interface{}{"*": false, "__id": true, "price": true}, last["fields"])
+	})
+
+	t.Run("scan_keeps_created_at", func(t *testing.T) {
+		got, err := goods.Search().Select("price").Scan(ctxBg, ScanOptions{})
+		require.NoError(t, err)
+		require.Len(t, got, 20)
+	})
+
+}
@@ -0,0 +1,53 @@
+package e365_gateway
+
+// OrderBy задает сортировку по возрастанию поля, заменяя ранее заданную
+func (s searchInstance[T]) OrderBy(field string) searchInstance[T] {
+	return s.orderBy(nil, field, true)
+}
+
+// OrderByDesc задает сортировку по убыванию поля, заменяя ранее заданную
+func (s searchInstance[T]) OrderByDesc(field string) searchInstance[T] {
+	return s.orderBy(nil, field, false)
+}
+
+// ThenBy добавляет следующий ключ сортировки по возрастанию поля
+func (s searchInstance[T]) ThenBy(field string) searchInstance[T] {
+	return s.orderBy(s.search.SortExpressions, field, true)
+}
+
+// ThenByDesc добавляет следующий ключ сортировки по убыванию поля
+func (s searchInstance[T]) ThenByDesc(field string) searchInstance[T] {
+	return s.orderBy(s.search.SortExpressions, field, false)
+}
+
+func (s searchInstance[T]) orderBy(prev []SortExpression, field string, asc bool) searchInstance[T] {
+	sort := make([]SortExpression, 0, len(prev)+1)
+	sort = append(sort, prev...)
+	s.search.SortExpressions = append(sort, SortExpression{Ascending: asc, Field: field})
+	return s
+}
+
+// stable возвращает копию поиска, порядок элементов в которой не меняется между страницами:
+// если сортировка не задана, элементы сортируются по __id
+func (s searchInstance[T]) stable() searchInstance[T] {
+	if len(s.search.SortExpressions) == 0 {
+		return s.OrderBy(fieldID)
+	}
+	return s
+}
+
+// withTieBreaker добавляет __id последним ключом сортировки, чтобы элементы с одинаковыми
+// значениями отсортированных полей не пропускались и не повторялись между страницами
+func withTieBreaker(sort []SortExpression) []SortExpression {
+	if len(sort) == 0 {
+		return sort
+	}
+	for _, se := range sort {
+		if se.Field == fieldID {
+			return sort
+		}
+	}
+	res := make([]SortExpression, 0, len(sort)+1)
+	res = append(res, sort...)
+	return append(res, SortExpression{Ascending: true, Field: fieldID})
+}
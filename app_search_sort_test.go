@@ -0,0 +1,73 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSort(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 250)
+	for i := 0; i < 250; i++ {
+		// много одинаковых цен, чтобы без __id порядок между страницами не был определен
+		items = append(items, fakeItem(249-i, start.Add(time.Duration(i)*time.Second), float64(i%3)))
+	}
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	t.Run("builder", func(t *testing.T) {
+		s := goods.Search().OrderByDesc("price").ThenBy("__name")
+		require.Equal(t, []SortExpression{
+			{Ascending: false, Field: "price"},
+			{Ascending: true, Field: "__name"},
+		}, s.search.SortExpressions)
+
+		f := s.newFilter(0, 10)
+		require.Equal(t, SortExpression{Ascending: true, Field: "__id"}, f.SortExpressions[2])
+		require.Len(t, s.search.SortExpressions, 2)
+
+		require.Len(t, s.OrderBy("price").search.SortExpressions, 1)
+		require.Empty(t, goods.Search().newFilter(0, 10).SortExpressions)
+		require.Len(t, s.newFilter(0, 0).SortExpressions, 2)
+	})
+
+	t.Run("iterator", func(t *testing.T) {
+		it := goods.Search().OrderBy("price").Size(30).Iter()
+		seen := make(map[string]struct{})
+		prevPrice := -1
+		pages := 0
+		err := it.Each(ctxBg, func(page []Product) error {
+			pages++
+			for _, item := range page {
+				require.GreaterOrEqual(t, item.Price, prevPrice)
+				prevPrice = item.Price
+				seen[item.ID] = struct{}{}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 9, pages)
+		require.Len(t, seen, 250)
+
+		_, err = it.Next(ctxBg)
+		require.ErrorIs(t, err, ErrNoMoreItems)
+	})
+
+	t.Run("all_at_once", func(t *testing.T) {
+		all, err := goods.Search().AllAtOnce(ctxBg, 3)
+		require.NoError(t, err)
+		seen := make(map[string]struct{})
+		for _, item := range all {
+			seen[item.ID] = struct{}{}
+		}
+		require.Len(t, seen, 250)
+		last := fa.Bodies[len(fa.Bodies)-1]
+		require.Equal(t, []interface{}{map[string]interface{}{"ascending": true, "field": "__id"}}, last["sortExpressions"])
+	})
+
+}
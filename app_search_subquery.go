@@ -0,0 +1,351 @@
+package e365_gateway
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	subqueryMaxIDs    = 10000
+	subqueryChunkSize = 500
+)
+
+// subquery - вложенный поиск, результат которого используется в условии InSearch (реализуется searchInstance)
+type subquery interface {
+	subqueryIDs(ctx context.Context, maxIDs int) ([]string, error)
+}
+
+// subqueryCondition - условие "поле типа Приложение ссылается на элементы, найденные вложенным поиском"
+type subqueryCondition struct {
+	field  string
+	search subquery
+	maxIDs int
+}
+
+// MarshalJSON возвращает ошибку: вложенный поиск должен быть выполнен до отправки запроса (см. resolve)
+func (sc *subqueryCondition) MarshalJSON() ([]byte, error) {
+	return nil, wrap(sc.field, ErrUnresolvedSubquery)
+}
+
+func (sc *subqueryCondition) fields() []string {
+	return []string{sc.field}
+}
+
+// InSearch - поле типа "Приложение" ссылается на один из элементов, найденных поиском search по другому приложению.
+// Перед выполнением основного поиска вложенный поиск выполняется постранично и превращается в условие Link.
+// Если вложенный поиск найдет больше 10000 элементов, вернется ErrSubqueryLimit (см. InSearchMax).
+func InSearch(field string, search subquery) Condition {
+	return InSearchMax(field, search, subqueryMaxIDs)
+}
+
+// InSearchMax работает как InSearch, но с ограничением maxIDs на кол-во элементов вложенного поиска.
+// Большой список id разбивается на пачки: основной поиск выполняется для каждой пачки, результаты объединяются
+// в порядке сортировки поиска. На пачки можно разбить только один вложенный поиск в условии (иначе ErrSubqueryChunks)
+func InSearchMax(field string, search subquery, maxIDs int) Condition {
+	if maxIDs < 1 {
+		maxIDs = subqueryMaxIDs
+	}
+	return &subqueryCondition{field: field, search: search, maxIDs: maxIDs}
+}
+
+// subqueryIDs получает id всех найденных элементов, но не более maxIDs
+func (s searchInstance[T]) subqueryIDs(ctx context.Context, maxIDs int) ([]string, error) {
+	ids := make([]string, 0)
+	err := s.Select(fieldID).Size(maxPageSize).Iter().Each(ctx, func(items []T) error {
+		for _, item := range items {
+			ids = append(ids, commonOf(item).ID)
+		}
+		if len(ids) > maxIDs {
+			return wrap(strconv.Itoa(maxIDs), ErrSubqueryLimit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// subqueryRef - вложенный поиск в дереве условий. negated - поиск находится под нечетным числом Not
+type subqueryRef struct {
+	cond    *subqueryCondition
+	negated bool
+}
+
+// collectSubqueries собирает все вложенные поиски из дерева условий
+func collectSubqueries(cond Condition, negated bool) []subqueryRef {
+	switch c := cond.(type) {
+	case *subqueryCondition:
+		return []subqueryRef{{cond: c, negated: negated}}
+	case group:
+		res := make([]subqueryRef, 0)
+		for _, item := range c.items {
+			res = append(res, collectSubqueries(item, negated)...)
+		}
+		return res
+	case negation:
+		return collectSubqueries(c.item, !negated)
+	case rowCondition:
+		return collectSubqueries(c.row, negated)
+	}
+	return nil
+}
+
+// replaceSubqueries возвращает копию дерева условий, в которой вложенные поиски заменены на repl
+func replaceSubqueries(cond Condition, repl map[*subqueryCondition]Condition) Condition {
+	switch c := cond.(type) {
+	case *subqueryCondition:
+		return repl[c]
+	case group:
+		items := make([]Condition, 0, len(c.items))
+		for _, item := range c.items {
+			items = append(items, replaceSubqueries(item, repl))
+		}
+		return group{op: c.op, items: items}
+	case negation:
+		return negation{item: replaceSubqueries(c.item, repl)}
+	case rowCondition:
+		return rowCondition{table: c.table, row: replaceSubqueries(c.row, repl)}
+	}
+	return cond
+}
+
+// resolve выполняет вложенные поиски и возвращает поиски без них: один, если все id поместились в одно условие,
+// иначе по одному на каждую пачку id.
+// Разбивать на пачки можно только вложенный поиск без отрицания: объединение результатов Not(Link(пачка))
+// совпало бы почти со всем приложением. Поэтому под Not пачки объединяются в одном условии через Or
+// (Not(Or(...)) - это И отрицаний пачек), а на части разбивается не более одного вложенного поиска
+func (s searchInstance[T]) resolve(ctx context.Context) ([]searchInstance[T], error) {
+
+	subqueries := collectSubqueries(s.search.Query, false)
+	if len(subqueries) == 0 {
+		return []searchInstance[T]{s}, nil
+	}
+
+	repl := make(map[*subqueryCondition]Condition, len(subqueries))
+	var split *subqueryCondition
+	var chunks []Condition
+	for _, sq := range subqueries {
+		ids, err := sq.cond.search.subqueryIDs(ctx, sq.cond.maxIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) <= subqueryChunkSize {
+			repl[sq.cond] = Link(sq.cond.field, ids...)
+			continue
+		}
+
+		links := make([]Condition, 0, 1+len(ids)/subqueryChunkSize)
+		for start := 0; start < len(ids); start += subqueryChunkSize {
+			end := start + subqueryChunkSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			links = append(links, Link(sq.cond.field, ids[start:end]...))
+		}
+		switch {
+		case sq.negated:
+			repl[sq.cond] = Or(links...)
+		case split != nil:
+			return nil, wrap(split.field+", "+sq.cond.field, ErrSubqueryChunks)
+		default:
+			split, chunks = sq.cond, links
+		}
+	}
+
+	if split == nil {
+		part := s
+		part.search.Query = replaceSubqueries(s.search.Query, repl)
+		return []searchInstance[T]{part}, nil
+	}
+	parts := make([]searchInstance[T], 0, len(chunks))
+	for _, chunk := range chunks {
+		repl[split] = chunk
+		part := s
+		part.search.Query = replaceSubqueries(s.search.Query, repl)
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// collectParts обходит части поиска (см. resolve) в порядке сортировки поиска и объединяет найденные элементы
+// без повторов. Обход останавливается, когда набрано limit элементов (limit < 0 - без ограничения)
+func collectParts[T interface{}](ctx context.Context, parts []searchInstance[T], limit int) ([]T, error) {
+	all := make([]T, 0)
+	m := newPartsMerge(parts)
+	for limit < 0 || len(all) < limit {
+		item, ok, err := m.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		all = append(all, item)
+	}
+	if err := parts[0].applyIncludes(ctx, all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// partsMerge - слияние отсортированных результатов частей поиска (см. resolve). Каждая часть обходится
+// своим итератором в порядке сортировки поиска (с __id последним ключом), а следующим возвращается
+// наименьший из первых элементов частей. Части читаются как DynamicItem, т.к. в T может не быть полей сортировки.
+// Элементы, попавшие в несколько частей, возвращаются один раз. Include не применяется
+type partsMerge[T interface{}] struct {
+	sort  []SortExpression
+	iters []*Iterator[DynamicItem]
+	heads [][]mergeEntry
+	done  []bool
+	seen  map[string]struct{}
+}
+
+type mergeEntry struct {
+	item DynamicItem
+	keys []interface{}
+}
+
+func newPartsMerge[T interface{}](parts []searchInstance[T]) *partsMerge[T] {
+	m := &partsMerge[T]{
+		iters: make([]*Iterator[DynamicItem], 0, len(parts)),
+		heads: make([][]mergeEntry, len(parts)),
+		done:  make([]bool, len(parts)),
+		seen:  make(map[string]struct{}),
+	}
+	for _, part := range parts {
+		part = part.stable()
+		m.sort = withTieBreaker(part.search.SortExpressions)
+		selected := part.selected
+		if len(selected) > 0 {
+			// поля сортировки нужны для слияния, даже если не выбраны
+			selected = append(selected[:len(selected):len(selected)], fieldID)
+			for _, se := range m.sort {
+				selected = append(selected, se.Field)
+			}
+		}
+		app := appAs[DynamicItem](*part.app)
+		dynamic := searchInstance[DynamicItem]{
+			search:         part.search,
+			includeDeleted: part.includeDeleted,
+			selected:       selected,
+			text:           part.text,
+			size:           maxPageSize,
+			app:            &app,
+		}
+		m.iters = append(m.iters, dynamic.Iter())
+	}
+	return m
+}
+
+// next возвращает следующий элемент в порядке сортировки. ok == false - элементы закончились
+func (m *partsMerge[T]) next(ctx context.Context) (item T, ok bool, err error) {
+	for {
+		best := -1
+		for i := range m.iters {
+			if len(m.heads[i]) == 0 && !m.done[i] {
+				items, err := m.iters[i].Next(ctx)
+				if errors.Is(err, ErrNoMoreItems) {
+					m.done[i] = true
+					continue
+				}
+				if err != nil {
+					return item, false, err
+				}
+				for _, d := range items {
+					m.heads[i] = append(m.heads[i], mergeEntry{item: d, keys: sortKeys(d, m.sort)})
+				}
+			}
+			if len(m.heads[i]) == 0 {
+				continue
+			}
+			if best < 0 || compareSortKeys(m.heads[i][0].keys, m.heads[best][0].keys, m.sort) < 0 {
+				best = i
+			}
+		}
+		if best < 0 {
+			return item, false, nil
+		}
+
+		e := m.heads[best][0]
+		m.heads[best] = m.heads[best][1:]
+		id := e.item.Common().ID
+		if _, dup := m.seen[id]; dup {
+			continue
+		}
+		m.seen[id] = struct{}{}
+		if item, err = FromDynamic[T](e.item); err != nil {
+			return item, false, err
+		}
+		return item, true, nil
+	}
+}
+
+// sortKeys возвращает значения полей сортировки элемента
+func sortKeys(d DynamicItem, sort []SortExpression) []interface{} {
+	keys := make([]interface{}, len(sort))
+	for i, se := range sort {
+		if raw, ok := d.Raw(se.Field); ok {
+			_ = json.Unmarshal(raw, &keys[i])
+		}
+	}
+	return keys
+}
+
+func compareSortKeys(a, b []interface{}, sort []SortExpression) int {
+	for i, se := range sort {
+		c := compareSortValues(a[i], b[i])
+		if c == 0 {
+			continue
+		}
+		if !se.Ascending {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+// compareSortValues сравнивает значения полей: незаполненные поля меньше заполненных,
+// числа и даты (RFC3339) сравниваются по значению, остальное - по json представлению
+func compareSortValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return cmp.Compare(av, bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			at, aerr := time.Parse(time.RFC3339, av)
+			bt, berr := time.Parse(time.RFC3339, bv)
+			if aerr == nil && berr == nil {
+				return at.Compare(bt)
+			}
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok && av != bv {
+			if av {
+				return 1
+			}
+			return -1
+		}
+	}
+	as, _ := json.Marshal(a)
+	bs, _ := json.Marshal(b)
+	return bytes.Compare(as, bs)
+}
@@ -0,0 +1,156 @@
+package e365_gateway
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSubquery(t *testing.T) {
+
+	type Customer struct {
+		AppCommon
+		Region string `json:"region"`
+	}
+	type Order struct {
+		AppCommon
+		Customer AppRef[Customer] `json:"customer"`
+	}
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	customers := make([]map[string]interface{}, 0, 1200)
+	for i := 0; i < 1200; i++ {
+		customer := fakeItem(i, start, 0)
+		customer["region"] = []string{"north", "south"}[i%2]
+		customers = append(customers, customer)
+	}
+	orders := make([]map[string]interface{}, 0, 2400)
+	for i := 0; i < 2400; i++ {
+		order := fakeItem(10000+i, start, float64(i))
+		order["customer"] = []interface{}{fakeID(i % 1200)}
+		orders = append(orders, order)
+	}
+
+	_, customersSettings := newFakeApp(t, customers)
+	fo, ordersSettings := newFakeApp(t, orders)
+	customerApp := NewApp[Customer](customersSettings)
+	orderApp := NewApp[Order](ordersSettings)
+	ctxBg := context.Background()
+
+	north := customerApp.Search().Match(Eq("region", "north"))
+
+	t.Run("single_chunk", func(t *testing.T) {
+		small := north.Match(In("__id", fakeID(0), fakeID(2), fakeID(4)))
+		search := orderApp.Search().Match(InSearch("customer", small))
+
+		count, err := search.Count(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, 6, count)
+
+		items, err := search.Include("customer", customerApp).All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, items, 6)
+		for _, item := range items {
+			customer, ok := item.Customer.Item()
+			require.True(t, ok)
+			require.Equal(t, "north", customer.Region)
+		}
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		lists := fo.Lists
+		search := orderApp.Search().Match(Or(InSearch("customer", north), Eq("price", 1)))
+
+		count, err := search.Count(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, 1201, count)
+		require.Greater(t, fo.Lists-lists, 1)
+
+		all, err := search.AllAtOnce(ctxBg, 4)
+		require.NoError(t, err)
+		require.Len(t, all, 1201)
+
+		page, err := search.From(1198).Size(10).All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, page, 3)
+
+		first, err := search.From(5).First(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, all[5].ID, first.ID)
+
+		scanned, err := search.Scan(ctxBg, ScanOptions{Field: "price", NumberFrom: 0, NumberTo: 2400, Windows: 3})
+		require.NoError(t, err)
+		require.Len(t, scanned, 1201)
+
+		ids := make(map[string]struct{})
+		err = search.Size(100).Iter().Each(ctxBg, func(items []Order) error {
+			for _, item := range items {
+				ids[item.ID] = struct{}{}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, ids, 1201)
+	})
+
+	t.Run("chunked_not", func(t *testing.T) {
+		// 600 id северных клиентов не помещаются в одну пачку
+		search := orderApp.Search().Match(Not(InSearch("customer", north)))
+
+		count, err := search.Count(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, 1200, count)
+
+		items, err := search.Size(100).All(ctxBg)
+		require.NoError(t, err)
+		require.Len(t, items, 100)
+		for _, item := range items {
+			id, err := strconv.Atoi(item.Customer.ID[24:])
+			require.NoError(t, err)
+			require.Equal(t, 1, id%2)
+		}
+	})
+
+	t.Run("chunked_sorted", func(t *testing.T) {
+		search := orderApp.Search().Match(Or(InSearch("customer", north), Eq("price", 1))).OrderByDesc("price")
+
+		first, err := search.First(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, fakeID(10000+2398), first.ID)
+
+		first, err = search.From(2).First(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, fakeID(10000+2394), first.ID)
+
+		page, err := search.From(1198).Size(10).All(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, []string{fakeID(10000 + 2), fakeID(10000 + 1), fakeID(10000)}, []string{page[0].ID, page[1].ID, page[2].ID})
+
+		prev := -1
+		err = orderApp.Search().Match(InSearch("customer", north)).OrderBy("price").Size(100).Iter().Each(ctxBg, func(items []Order) error {
+			for _, item := range items {
+				n, err := strconv.Atoi(item.ID[24:])
+				require.NoError(t, err)
+				require.Greater(t, n, prev)
+				prev = n
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("several_chunked", func(t *testing.T) {
+		south := customerApp.Search().Match(Eq("region", "south"))
+		_, err := orderApp.Search().Match(InSearch("customer", north), InSearch("customer", south)).All(ctxBg)
+		require.ErrorIs(t, err, ErrSubqueryChunks)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		_, err := orderApp.Search().Match(InSearchMax("customer", north, 100)).All(ctxBg)
+		require.ErrorIs(t, err, ErrSubqueryLimit)
+	})
+
+}
@@ -0,0 +1,53 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchText(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		item := fakeItem(i, start.Add(time.Duration(i)*time.Second), float64(i))
+		if i%3 == 0 {
+			item["__name"] = "ACME Ltd #" + fakeID(i)
+		}
+		items = append(items, item)
+	}
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	search := goods.Search().Text("  acme ltd ")
+
+	count, err := search.Count(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, 50, count)
+	require.Equal(t, "acme ltd", fa.Bodies[len(fa.Bodies)-1]["searchString"])
+
+	count, err = search.Where(SearchFilter{Fields: Fields{"price": Field.Number().To(29)}}).Count(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	first, err := search.OrderByDesc("price").First(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, 147, first.Price)
+
+	all, err := search.AllAtOnce(ctxBg, 2)
+	require.NoError(t, err)
+	require.Len(t, all, 50)
+
+	page, err := search.Size(20).Iter().Next(ctxBg)
+	require.NoError(t, err)
+	require.Len(t, page, 20)
+
+	_, err = goods.Search().Count(ctxBg)
+	require.NoError(t, err)
+	require.NotContains(t, fa.Bodies[len(fa.Bodies)-1], "searchString")
+
+}
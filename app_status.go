@@ -0,0 +1,198 @@
+package e365_gateway
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const statusBookTTL = 5 * time.Minute
+
+// StatusBook - справочник статусов приложения. Кэширует StatusInfo на время ttl
+// и позволяет работать со статусами по id, коду или названию
+type StatusBook[T interface{}] struct {
+	app      App[T]
+	ttl      time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+	info     StatusInfo
+	loadedAt time.Time
+}
+
+// NewStatusBook создает справочник статусов приложения app.
+// StatusInfo запрашивается при первом обращении и обновляется не чаще, чем раз в ttl (по умолчанию 5 минут)
+func NewStatusBook[T interface{}](app App[T], ttl time.Duration) *StatusBook[T] {
+	if ttl <= 0 {
+		ttl = statusBookTTL
+	}
+	return &StatusBook[T]{
+		app: app,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// Info возвращает StatusInfo из кэша или запрашивает его, если кэш устарел
+func (sb *StatusBook[T]) Info(ctx context.Context) (StatusInfo, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if !sb.loadedAt.IsZero() && sb.now().Sub(sb.loadedAt) < sb.ttl {
+		return sb.info, nil
+	}
+	info, err := sb.app.GetStatusInfo(ctx)
+	if err != nil {
+		return StatusInfo{}, err
+	}
+	sb.info = info
+	sb.loadedAt = sb.now()
+	return info, nil
+}
+
+// Reset сбрасывает кэш: следующее обращение запросит StatusInfo заново
+func (sb *StatusBook[T]) Reset() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.loadedAt = time.Time{}
+}
+
+// ByID возвращает статус по id (значение AppCommon.Status.Status)
+func (sb *StatusBook[T]) ByID(ctx context.Context, id int) (StatusItem, error) {
+	return sb.find(ctx, strconv.Itoa(id), func(s StatusItem) bool {
+		return s.Id == id
+	})
+}
+
+// ByCode возвращает статус по коду
+func (sb *StatusBook[T]) ByCode(ctx context.Context, code string) (StatusItem, error) {
+	return sb.find(ctx, code, func(s StatusItem) bool {
+		return s.Code == code
+	})
+}
+
+// ByName возвращает статус по названию (без учета регистра и пробелов по краям)
+func (sb *StatusBook[T]) ByName(ctx context.Context, name string) (StatusItem, error) {
+	return sb.find(ctx, name, func(s StatusItem) bool {
+		return sameName(s.Name, name)
+	})
+}
+
+// Lookup возвращает статус по коду или, если такого кода нет, по названию
+func (sb *StatusBook[T]) Lookup(ctx context.Context, codeOrName string) (StatusItem, error) {
+	return sb.find(ctx, codeOrName, func(s StatusItem) bool {
+		return s.Code == codeOrName
+	}, func(s StatusItem) bool {
+		return sameName(s.Name, codeOrName)
+	})
+}
+
+// find ищет статус по условиям match в порядке их приоритета
+func (sb *StatusBook[T]) find(ctx context.Context, key string, match ...func(s StatusItem) bool) (StatusItem, error) {
+	info, err := sb.Info(ctx)
+	if err != nil {
+		return StatusItem{}, err
+	}
+	for _, m := range match {
+		for _, s := range info.StatusItems {
+			if m(s) {
+				return s, nil
+			}
+		}
+	}
+	codes := make([]string, 0, len(info.StatusItems))
+	for _, s := range info.StatusItems {
+		codes = append(codes, s.Code)
+	}
+	return StatusItem{}, wrap(fmt.Sprintf("%q (available: %s)", key, strings.Join(codes, ", ")), ErrUnknownStatus)
+}
+
+// Group возвращает группу статусов по id, коду или названию
+func (sb *StatusBook[T]) Group(ctx context.Context, key string) (GroupItem, error) {
+	info, err := sb.Info(ctx)
+	if err != nil {
+		return GroupItem{}, err
+	}
+	for _, g := range info.GroupItems {
+		if g.Id == key || g.Code == key {
+			return g, nil
+		}
+	}
+	for _, g := range info.GroupItems {
+		if sameName(g.Name, key) {
+			return g, nil
+		}
+	}
+	return GroupItem{}, wrap(fmt.Sprintf("group %q", key), ErrUnknownStatus)
+}
+
+// StatusOf возвращает текущий статус элемента (по AppCommon.Status.Status)
+func (sb *StatusBook[T]) StatusOf(ctx context.Context, item T) (StatusItem, error) {
+	return sb.ByID(ctx, commonOf(item).Status.Status)
+}
+
+// StatusCode возвращает код текущего статуса элемента
+func (sb *StatusBook[T]) StatusCode(ctx context.Context, item T) (string, error) {
+	s, err := sb.StatusOf(ctx, item)
+	if err != nil {
+		return "", err
+	}
+	return s.Code, nil
+}
+
+// StatusGroup возвращает группу текущего статуса элемента
+func (sb *StatusBook[T]) StatusGroup(ctx context.Context, item T) (GroupItem, error) {
+	s, err := sb.StatusOf(ctx, item)
+	if err != nil {
+		return GroupItem{}, err
+	}
+	return sb.Group(ctx, s.GroupId)
+}
+
+// SetStatus проверяет, что статус с кодом code существует, и меняет статус элемента с переданным id.
+// Для несуществующего кода запрос не отправляется, возвращается ErrUnknownStatus со списком доступных кодов
+func (sb *StatusBook[T]) SetStatus(ctx context.Context, id, code string) (T, error) {
+	var nilT T
+	if _, err := sb.ByCode(ctx, code); err != nil {
+		return nilT, err
+	}
+	return sb.app.SetStatus(ctx, id, code)
+}
+
+// SetStatusByName меняет статус элемента с переданным id на статус с названием name
+func (sb *StatusBook[T]) SetStatusByName(ctx context.Context, id, name string) (T, error) {
+	var nilT T
+	s, err := sb.ByName(ctx, name)
+	if err != nil {
+		return nilT, err
+	}
+	return sb.app.SetStatus(ctx, id, s.Code)
+}
+
+// Codes возвращает коды статусов по кодам или названиям, например, для SearchFilter.AtStatus
+func (sb *StatusBook[T]) Codes(ctx context.Context, codesOrNames ...string) ([]string, error) {
+	codes := make([]string, 0, len(codesOrNames))
+	for _, key := range codesOrNames {
+		s, err := sb.Lookup(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, s.Code)
+	}
+	return codes, nil
+}
+
+// GroupID возвращает id группы статусов по id, коду или названию, например, для SearchFilter.StatusGroupId
+func (sb *StatusBook[T]) GroupID(ctx context.Context, key string) (string, error) {
+	g, err := sb.Group(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return g.Id, nil
+}
+
+func sameName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
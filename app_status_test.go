@@ -0,0 +1,100 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testStatusInfo = StatusInfo{
+	StatusItems: []StatusItem{
+		{Id: 1, Name: "Новый", Code: "new", GroupId: "g-open"},
+		{Id: 2, Name: "В работе", Code: "in_work", GroupId: "g-open"},
+		{Id: 3, Name: "Закрыт", Code: "closed", GroupId: "g-done"},
+	},
+	GroupItems: []GroupItem{
+		{Id: "g-open", Code: "open", Name: "Открытые"},
+		{Id: "g-done", Code: "done", Name: "Завершенные"},
+	},
+}
+
+func TestStatusBook(t *testing.T) {
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	items := []map[string]interface{}{fakeItem(1, start, 10), fakeItem(2, start, 20)}
+	items[0]["__status"] = map[string]interface{}{"order": float64(0), "status": float64(1)}
+	fa, settings := newFakeApp(t, items)
+	fa.StatusInfo = testStatusInfo
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	now := start
+	book := NewStatusBook(goods, time.Minute)
+	book.now = func() time.Time { return now }
+
+	t.Run("cache", func(t *testing.T) {
+		s, err := book.ByCode(ctxBg, "in_work")
+		require.NoError(t, err)
+		require.Equal(t, 2, s.Id)
+		_, err = book.ByID(ctxBg, 3)
+		require.NoError(t, err)
+		require.Equal(t, 1, fa.Hits["/status"])
+
+		now = now.Add(2 * time.Minute)
+		_, err = book.ByName(ctxBg, " в работе ")
+		require.NoError(t, err)
+		require.Equal(t, 2, fa.Hits["/status"])
+
+		book.Reset()
+		_, err = book.Info(ctxBg)
+		require.NoError(t, err)
+		require.Equal(t, 3, fa.Hits["/status"])
+	})
+
+	t.Run("item_status", func(t *testing.T) {
+		item, err := goods.GetByID(ctxBg, fakeID(1))
+		require.NoError(t, err)
+		code, err := book.StatusCode(ctxBg, item)
+		require.NoError(t, err)
+		require.Equal(t, "new", code)
+		group, err := book.StatusGroup(ctxBg, item)
+		require.NoError(t, err)
+		require.Equal(t, "open", group.Code)
+	})
+
+	t.Run("set_status", func(t *testing.T) {
+		item, err := book.SetStatusByName(ctxBg, fakeID(2), "Закрыт")
+		require.NoError(t, err)
+		require.Equal(t, 3, item.Status.Status)
+
+		item, err = book.SetStatus(ctxBg, fakeID(2), "in_work")
+		require.NoError(t, err)
+		require.Equal(t, 2, item.Status.Status)
+
+		calls := fa.Hits["/set-status"]
+		_, err = book.SetStatus(ctxBg, fakeID(2), "archived")
+		require.ErrorIs(t, err, ErrUnknownStatus)
+		require.Contains(t, err.Error(), "available: new, in_work, closed")
+		_, err = book.SetStatusByName(ctxBg, fakeID(2), "Архив")
+		require.ErrorIs(t, err, ErrUnknownStatus)
+		require.Equal(t, calls, fa.Hits["/set-status"])
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		codes, err := book.Codes(ctxBg, "Новый", "closed")
+		require.NoError(t, err)
+		require.Equal(t, []string{"new", "closed"}, codes)
+
+		groupID, err := book.GroupID(ctxBg, "Завершенные")
+		require.NoError(t, err)
+		require.Equal(t, "g-done", groupID)
+
+		_, err = book.Codes(ctxBg, "unknown")
+		require.ErrorIs(t, err, ErrUnknownStatus)
+		_, err = book.GroupID(ctxBg, "unknown")
+		require.ErrorIs(t, err, ErrUnknownStatus)
+	})
+
+}
@@ -0,0 +1,330 @@
+package e365_gateway
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	fieldUpdatedAt = "__updatedAt"
+	fieldDeletedAt = "__deletedAt"
+	fieldStatus    = "__status"
+
+	watchInterval = 10 * time.Second
+	watchOverlap  = 2 * time.Second
+)
+
+// EventType - тип изменения элемента приложения
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+)
+
+// Event - изменение элемента приложения, найденное Watch
+type Event[T interface{}] struct {
+	Type EventType
+	Item T
+	// Err - ошибка опроса сервера (Type и Item в таком событии пустые), Watch продолжит опрос через Interval
+	Err error
+}
+
+// Checkpoint - позиция Watch: время последнего изменения и версии элементов, изменившихся около него.
+// Версии нужны, чтобы не пропускать изменения, сделанные в ту же секунду, и не повторять уже отправленные события
+type Checkpoint struct {
+	Watermark time.Time `json:"watermark"`
+	// Seen - __id -> версия элемента ("3" или "3:deleted")
+	Seen map[string]string `json:"seen"`
+}
+
+// CheckpointStore - хранилище позиций Watch (например, файл, redis или таблица в БД)
+type CheckpointStore interface {
+	// Load возвращает сохраненную позицию по ключу. ok = false, если позиции еще нет
+	Load(ctx context.Context, key string) (cp Checkpoint, ok bool, err error)
+	Save(ctx context.Context, key string, cp Checkpoint) error
+}
+
+// MemoryCheckpointStore - хранилище позиций в памяти процесса
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpointStore создает хранилище позиций в памяти процесса
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]Checkpoint)}
+}
+
+func (ms *MemoryCheckpointStore) Load(_ context.Context, key string) (Checkpoint, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	cp, ok := ms.checkpoints[key]
+	return cp, ok, nil
+}
+
+func (ms *MemoryCheckpointStore) Save(_ context.Context, key string, cp Checkpoint) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.checkpoints[key] = cp
+	return nil
+}
+
+// WatchOptions - параметры Watch
+type WatchOptions struct {
+	// Interval - пауза между опросами сервера (по умолчанию 10 секунд)
+	Interval time.Duration
+	// Overlap - насколько раньше последнего изменения начинается следующий опрос (по умолчанию 2 секунды).
+	// Фильтр по дате работает с точностью до секунды, а изменения, сохраненные с задержкой, могут получить более раннее время
+	Overlap time.Duration
+	// Since - время, с которого отслеживаются изменения, если в Store нет сохраненной позиции. По умолчанию - последнее
+	// изменение в приложении по времени сервера: изменения, сделанные до первого опроса, не отправляются
+	Since time.Time
+	// Store - хранилище позиции (для Watch по умолчанию в памяти процесса, для Poll обязательно).
+	// Позиция сохраняется после отправки всех событий опроса
+	Store CheckpointStore
+	// Key - ключ позиции в Store (по умолчанию namespace.code приложения)
+	Key string
+	// Buffer - размер буфера канала событий
+	Buffer int
+}
+
+// Watch отслеживает изменения элементов приложения, подходящих под фильтр sf, и отправляет их в канал.
+// Сервер опрашивается каждые Interval: запрашиваются элементы (включая удаленные), у которых __updatedAt или __deletedAt
+// не раньше последнего найденного изменения. Повторы отсекаются по __id и __version.
+// Ошибки опроса передаются событием с Err. Канал закрывается при отмене ctx
+func (app App[T]) Watch(ctx context.Context, sf SearchFilter, opts WatchOptions) <-chan Event[T] {
+
+	w := app.watcher(sf, opts)
+	events := make(chan Event[T], opts.Buffer)
+
+	go func() {
+		defer close(events)
+		send := func(e Event[T]) error {
+			select {
+			case events <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for {
+			if err := w.poll(ctx, send); err != nil && ctx.Err() == nil {
+				if send(Event[T]{Err: err}) != nil {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.interval):
+			}
+		}
+	}()
+
+	return events
+}
+
+// Poll выполняет один опрос, как Watch, и передает найденные изменения в fn по порядку.
+// Следующий вызов продолжает с позиции, сохраненной в opts.Store (обязательно), причем только если fn обработала
+// все события без ошибок, поэтому после сбоя изменения будут получены повторно. Interval и Buffer не используются
+func (app App[T]) Poll(ctx context.Context, sf SearchFilter, opts WatchOptions, fn func(e Event[T]) error) error {
+	if opts.Store == nil {
+		return ErrNoCheckpointStore
+	}
+	return app.watcher(sf, opts).poll(ctx, fn)
+}
+
+// PollBatch работает как Poll, но передает изменения в fn пачками по 100 (например, чтобы проверить
+// или записать их одним запросом)
+func (app App[T]) PollBatch(ctx context.Context, sf SearchFilter, opts WatchOptions, fn func(events []Event[T]) error) error {
+	if opts.Store == nil {
+		return ErrNoCheckpointStore
+	}
+	return app.watcher(sf, opts).pollBatch(ctx, fn)
+}
+
+// watcher - состояние Watch между опросами
+type watcher[T interface{}] struct {
+	search searchInstance[T]
+	// all - все элементы приложения, по ним определяется начальная позиция
+	all      searchInstance[T]
+	store    CheckpointStore
+	key      string
+	since    time.Time
+	interval time.Duration
+	overlap  time.Duration
+}
+
+func (app App[T]) watcher(sf SearchFilter, opts WatchOptions) *watcher[T] {
+	w := &watcher[T]{
+		search:   app.Search().Where(sf).IncludeDeleted(),
+		all:      app.Search().IncludeDeleted(),
+		store:    opts.Store,
+		key:      opts.Key,
+		since:    opts.Since,
+		interval: opts.Interval,
+		overlap:  opts.Overlap,
+	}
+	if w.store == nil {
+		w.store = NewMemoryCheckpointStore()
+	}
+	if w.key == "" {
+		w.key = app.namespace + "." + app.code
+	}
+	if w.interval <= 0 {
+		w.interval = watchInterval
+	}
+	if w.overlap <= 0 {
+		w.overlap = watchOverlap
+	}
+	return w
+}
+
+// poll выполняет один опрос: передает новые изменения в handle и сохраняет позицию
+func (w *watcher[T]) poll(ctx context.Context, handle func(e Event[T]) error) error {
+	return w.pollBatch(ctx, func(events []Event[T]) error {
+		for _, e := range events {
+			if err := handle(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pollBatch выполняет один опрос: передает новые изменения в handle пачками по scanPageSize и сохраняет позицию
+func (w *watcher[T]) pollBatch(ctx context.Context, handle func(events []Event[T]) error) error {
+
+	cp, ok, err := w.store.Load(ctx, w.key)
+	if err != nil {
+		return err
+	}
+	start := cp.Watermark.Add(-w.overlap)
+	switch {
+	case ok:
+	case w.since.IsZero():
+		if cp, err = w.baseline(ctx); err != nil {
+			return err
+		}
+		start = cp.Watermark
+	default:
+		cp = Checkpoint{Watermark: w.since}
+		start = w.since
+	}
+
+	from := Field.DateTime().From(start)
+	search := w.search.Match(Or(Fields{fieldUpdatedAt: from}, Fields{fieldDeletedAt: from}))
+
+	if err = search.check(); err != nil {
+		return err
+	}
+	parts, err := search.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := Checkpoint{Watermark: cp.Watermark, Seen: make(map[string]string)}
+	changed := make(map[string]time.Time)
+	events := make([]Event[T], 0)
+	collect := func(items []T) error {
+		for _, item := range items {
+			c := commonOf(item)
+			changedAt, version := changeOf(c)
+			if changedAt.After(next.Watermark) {
+				next.Watermark = changedAt
+			}
+			changed[c.ID] = changedAt
+
+			event := Event[T]{Type: EventUpdated, Item: item}
+			switch {
+			case !c.DeletedAt.IsZero():
+				event.Type = EventDeleted
+			case c.Version <= 1 || c.CreatedAt.Equal(c.UpdatedAt):
+				event.Type = EventCreated
+			}
+
+			if next.Seen[c.ID] == version {
+				// уже получен в этом опросе
+				continue
+			}
+			next.Seen[c.ID] = version
+			if cp.Seen[c.ID] == version {
+				continue
+			}
+			events = append(events, event)
+		}
+		return nil
+	}
+	// страницы идут по ключу (__updatedAt, __id), а не по смещению: элемент, измененный во время опроса,
+	// переезжает в конец выборки и не сдвигает еще не полученные элементы
+	for _, part := range parts {
+		if err = part.scanKeyset(ctx, fieldUpdatedAt, time.Time{}, collect); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(events); start += scanPageSize {
+		end := min(start+scanPageSize, len(events))
+		if err = handle(events[start:end]); err != nil {
+			return err
+		}
+	}
+
+	// в следующий опрос попадут только элементы, измененные не раньше watermark - overlap
+	edge := next.Watermark.Add(-w.overlap).Truncate(time.Second)
+	for id, changedAt := range changed {
+		if changedAt.Before(edge) {
+			delete(next.Seen, id)
+		}
+	}
+
+	return w.store.Save(ctx, w.key, next)
+}
+
+// baseline возвращает начальную позицию по времени сервера, а не по локальным часам: последнее изменение в приложении
+// и версии элементов, измененных в ту же секунду (их изменения уже произошли и не отправляются)
+func (w *watcher[T]) baseline(ctx context.Context) (Checkpoint, error) {
+
+	cp := Checkpoint{Watermark: time.Unix(0, 0).UTC(), Seen: make(map[string]string)}
+	latest := make([]T, 0)
+	for _, field := range []string{fieldUpdatedAt, fieldDeletedAt} {
+		page := w.all.OrderByDesc(field)
+		items, _, err := page.app.find(ctx, page.newFilter(0, scanPageSize))
+		if err != nil {
+			return cp, err
+		}
+		latest = append(latest, items...)
+	}
+
+	for _, item := range latest {
+		if changedAt, _ := changeOf(commonOf(item)); changedAt.After(cp.Watermark) {
+			cp.Watermark = changedAt
+		}
+	}
+	edge := cp.Watermark.Truncate(time.Second)
+	for _, item := range latest {
+		c := commonOf(item)
+		if changedAt, version := changeOf(c); !changedAt.Before(edge) {
+			cp.Seen[c.ID] = version
+		}
+	}
+	return cp, nil
+}
+
+// changeOf возвращает время последнего изменения элемента и его версию для Checkpoint.Seen
+func changeOf(c AppCommon) (time.Time, string) {
+	changedAt := c.UpdatedAt
+	if c.DeletedAt.After(changedAt) {
+		changedAt = c.DeletedAt
+	}
+	version := strconv.Itoa(c.Version)
+	if !c.DeletedAt.IsZero() {
+		version += ":deleted"
+	}
+	return changedAt, version
+}
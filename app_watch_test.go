@@ -0,0 +1,188 @@
+package e365_gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+
+	base := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		items = append(items, fakeItem(i, base.Add(time.Duration(i)*time.Second), float64(i)))
+	}
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+	ctxBg := context.Background()
+
+	store := NewMemoryCheckpointStore()
+	w := goods.watcher(SearchFilter{Fields: Fields{"price": Field.Number().From(10)}}, WatchOptions{
+		Since: base.Add(100 * time.Second),
+		Store: store,
+	})
+	poll := func() []Event[Product] {
+		events := make([]Event[Product], 0)
+		require.NoError(t, w.poll(ctxBg, func(e Event[Product]) error {
+			events = append(events, e)
+			return nil
+		}))
+		return events
+	}
+	edit := func(n int, at time.Time, fields map[string]interface{}) {
+		fa.Lock()
+		defer fa.Unlock()
+		item := fa.Find(fakeID(n))
+		for k, v := range fields {
+			item[k] = v
+		}
+		item["__version"] = item["__version"].(float64) + 1
+		item["__updatedAt"] = at.Format(time.RFC3339Nano)
+	}
+
+	t.Run("initial", func(t *testing.T) {
+		events := poll()
+		require.Len(t, events, 50)
+		require.Equal(t, EventCreated, events[0].Type)
+		require.Equal(t, fakeID(100), events[0].Item.ID)
+
+		cp, ok, err := store.Load(ctxBg, "ns.app")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, base.Add(149*time.Second), cp.Watermark)
+
+		require.Empty(t, poll())
+	})
+
+	t.Run("same_second_edits", func(t *testing.T) {
+		last := base.Add(149 * time.Second)
+		edit(149, last.Add(300*time.Millisecond), map[string]interface{}{"price": float64(1000)})
+		edit(120, last.Add(300*time.Millisecond), nil)
+
+		events := poll()
+		require.Len(t, events, 2)
+		require.Equal(t, EventUpdated, events[0].Type)
+		require.Equal(t, fakeID(120), events[0].Item.ID)
+		require.Equal(t, 1000, events[1].Item.Price)
+
+		// еще одно изменение в ту же секунду
+		edit(149, last.Add(700*time.Millisecond), nil)
+		events = poll()
+		require.Len(t, events, 1)
+		require.Equal(t, 3, events[0].Item.Version)
+
+		require.Empty(t, poll())
+	})
+
+	t.Run("filtered_out_and_deleted", func(t *testing.T) {
+		at := base.Add(200 * time.Second)
+		edit(5, at, nil)
+		edit(130, at, map[string]interface{}{"__deletedAt": at.Format(time.RFC3339)})
+
+		events := poll()
+		require.Len(t, events, 1)
+		require.Equal(t, EventDeleted, events[0].Type)
+		require.Equal(t, fakeID(130), events[0].Item.ID)
+		require.Empty(t, poll())
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		at := base.Add(300 * time.Second)
+		edit(140, at, nil)
+
+		// новый watcher с тем же хранилищем продолжает с сохраненной позиции
+		events := make([]Event[Product], 0)
+		err := goods.Poll(ctxBg, SearchFilter{Fields: Fields{"price": Field.Number().From(10)}}, WatchOptions{Store: store}, func(e Event[Product]) error {
+			events = append(events, e)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, fakeID(140), events[0].Item.ID)
+	})
+
+	t.Run("poll_since_server_time", func(t *testing.T) {
+		err := goods.Poll(ctxBg, SearchFilter{}, WatchOptions{}, func(e Event[Product]) error { return nil })
+		require.ErrorIs(t, err, ErrNoCheckpointStore)
+
+		// без Since опрос начинается с последнего изменения на сервере, а не с локального времени
+		store := NewMemoryCheckpointStore()
+		events := make([]Event[Product], 0)
+		collect := func(e Event[Product]) error {
+			events = append(events, e)
+			return nil
+		}
+		require.NoError(t, goods.Poll(ctxBg, SearchFilter{}, WatchOptions{Store: store}, collect))
+		require.Empty(t, events)
+
+		edit(7, base.Add(400*time.Second), nil)
+		require.NoError(t, goods.Poll(ctxBg, SearchFilter{}, WatchOptions{Store: store}, collect))
+		require.Len(t, events, 1)
+		require.Equal(t, fakeID(7), events[0].Item.ID)
+
+		require.NoError(t, goods.Poll(ctxBg, SearchFilter{}, WatchOptions{Store: store}, collect))
+		require.Len(t, events, 1)
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctxBg)
+		events := goods.Watch(ctx, SearchFilter{}, WatchOptions{
+			Since:    base.Add(250 * time.Second),
+			Interval: 10 * time.Millisecond,
+			Key:      "channel",
+			Store:    store,
+		})
+
+		e := <-events
+		require.NoError(t, e.Err)
+		require.Equal(t, fakeID(140), e.Item.ID)
+
+		_, err := goods.Update(ctxBg, fakeID(1), Product{Price: 7})
+		require.NoError(t, err)
+		for e = range events {
+			require.NoError(t, e.Err)
+			if e.Item.ID == fakeID(1) {
+				break
+			}
+		}
+		require.Equal(t, EventUpdated, e.Type)
+		require.Equal(t, 7, e.Item.Price)
+
+		cancel()
+		for range events {
+		}
+	})
+
+}
+
+func TestWatchEditBetweenPages(t *testing.T) {
+
+	base := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	items := make([]map[string]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		items = append(items, fakeItem(i, base.Add(time.Duration(i)*time.Second), float64(i)))
+	}
+	fa, settings := newFakeApp(t, items)
+	goods := NewApp[Product](settings)
+
+	// после первой страницы элемент из нее изменяется и уходит в конец сортировки по __updatedAt
+	fa.OnList = func() {
+		fa.OnList = nil
+		item := fa.Find(fakeID(10))
+		item["__version"] = float64(2)
+		item["__updatedAt"] = base.Add(time.Hour).Format(time.RFC3339Nano)
+	}
+
+	ids := make(map[string]int)
+	err := goods.Poll(context.Background(), SearchFilter{}, WatchOptions{Since: base, Store: NewMemoryCheckpointStore()}, func(e Event[Product]) error {
+		ids[e.Item.ID] = e.Item.Version
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 150)
+	require.Equal(t, 2, ids[fakeID(10)])
+	require.Equal(t, 1, ids[fakeID(100)])
+}
@@ -0,0 +1,305 @@
+// Package backup сохраняет элементы приложения elma365 в переносимый архив и восстанавливает их из него
+// (например, снимок перед массовым изменением или удалением).
+//
+// Архив - tar со следующими файлами:
+//
+//	manifest.json - приложение, граница снимка, кол-во элементов и файлов, недоступные файлы (Manifest)
+//	schema.json   - описание полей приложения (AppSchema)
+//	status.json   - статусы приложения (StatusInfo)
+//	files/<id>    - содержимое файлов из полей типа "Файл" (если в DumpOptions задан Files)
+//	items.jsonl   - элементы приложения, включая удаленные, по одному на строку
+//
+// Поддерживается только формат tar (zip не поддерживается). Для сжатия архива передайте в Dump и Restore
+// gzip.Writer и gzip.Reader.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+const (
+	formatVersion = 1
+
+	fileManifest = "manifest.json"
+	fileSchema   = "schema.json"
+	fileStatus   = "status.json"
+	fileItems    = "items.jsonl"
+	dirFiles     = "files/"
+)
+
+var (
+	ErrInvalidArchive = errors.New("invalid backup archive")
+	ErrNotEmpty       = errors.New("app is not empty")
+)
+
+// Manifest - описание архива
+type Manifest struct {
+	Version   int       `json:"version"`
+	Namespace string    `json:"namespace"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Snapshot - время сервера, не позже которого созданы элементы архива (пустое, если в приложении не было элементов)
+	Snapshot time.Time `json:"snapshot,omitempty"`
+	// Items - кол-во элементов, включая удаленные
+	Items   int `json:"items"`
+	Deleted int `json:"deleted"`
+	Files   int `json:"files"`
+	// MissingFiles - id файлов, которые не удалось скачать (например, удаленных со стенда). В архив они не попадают
+	MissingFiles []string `json:"missingFiles,omitempty"`
+}
+
+// DumpOptions - параметры Dump
+type DumpOptions struct {
+	// Files - адаптер для скачивания файлов со стенда приложения. Если не задан, файлы в архив не сохраняются
+	// (в элементах остаются только их id)
+	Files *e365_gateway.FileAdapter
+}
+
+// Dump записывает в w архив со всеми элементами приложения (включая удаленные), созданными до начала Dump, его полями
+// и статусами. Элементы и файлы сначала сохраняются во временные файлы, т.к. размер каждого файла tar нужно знать
+// до записи. Файлы, которые не удалось скачать, не прерывают Dump и перечисляются в Manifest.MissingFiles
+func Dump(ctx context.Context, app e365_gateway.App[e365_gateway.DynamicItem], w io.Writer, opts DumpOptions) (Manifest, error) {
+
+	schema, err := app.Schema(ctx)
+	if err != nil {
+		return Manifest{}, err
+	}
+	status, err := app.GetStatusInfo(ctx)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest := Manifest{
+		Version:   formatVersion,
+		Namespace: schema.Namespace,
+		Code:      schema.Code,
+		CreatedAt: time.Now().UTC(),
+	}
+	// граница снимка берется по времени сервера (последнему созданному элементу), а не по локальным часам,
+	// которые могут отставать от сервера
+	latest, err := app.Search().IncludeDeleted().OrderByDesc("__createdAt").First(ctx)
+	if err != nil {
+		return manifest, err
+	}
+	if createdAt := latest.Common().CreatedAt.UTC(); !createdAt.IsZero() {
+		// фильтр по дате работает с точностью до секунды, поэтому граница округляется вверх
+		manifest.Snapshot = createdAt.Truncate(time.Second)
+		if manifest.Snapshot.Before(createdAt) {
+			manifest.Snapshot = manifest.Snapshot.Add(time.Second)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "elma365-backup-*")
+	if err != nil {
+		return manifest, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	tmp, err := os.Create(filepath.Join(dir, fileItems))
+	if err != nil {
+		return manifest, err
+	}
+	defer func() {
+		_ = tmp.Close()
+	}()
+
+	files := make(map[string]struct{})
+	bw := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(bw)
+	// верхняя граница __createdAt не дает попасть в архив элементам, созданным во время Dump
+	search := app.Search().IncludeDeleted()
+	if !manifest.Snapshot.IsZero() {
+		search = search.Where(e365_gateway.SearchFilter{Fields: e365_gateway.Fields{
+			"__createdAt": e365_gateway.Field.DateTime().To(manifest.Snapshot),
+		}})
+	}
+	err = search.ScanEach(ctx, e365_gateway.ScanOptions{}, func(items []e365_gateway.DynamicItem) error {
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+			manifest.Items++
+			if !item.Common().DeletedAt.IsZero() {
+				manifest.Deleted++
+			}
+			if opts.Files != nil {
+				for _, id := range fileIDs(schema, item) {
+					files[id] = struct{}{}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+	if err = bw.Flush(); err != nil {
+		return manifest, err
+	}
+
+	// файлы скачиваются до записи manifest.json, т.к. в нем перечисляются недоступные файлы
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	spooled := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err = spoolFile(ctx, opts.Files, filepath.Join(dir, "file-"+strconv.Itoa(len(spooled))), id); err != nil {
+			if ctx.Err() != nil {
+				return manifest, ctx.Err()
+			}
+			manifest.MissingFiles = append(manifest.MissingFiles, id)
+			continue
+		}
+		spooled = append(spooled, id)
+	}
+	manifest.Files = len(spooled)
+
+	tw := tar.NewWriter(w)
+	if err = writeJSON(tw, fileManifest, manifest); err != nil {
+		return manifest, err
+	}
+	if err = writeJSON(tw, fileSchema, schema); err != nil {
+		return manifest, err
+	}
+	if err = writeJSON(tw, fileStatus, status); err != nil {
+		return manifest, err
+	}
+
+	// файлы записываются до элементов, чтобы Restore загрузил их раньше, чем создаст ссылающиеся на них элементы
+	for i, id := range spooled {
+		if err = writeFile(tw, filepath.Join(dir, "file-"+strconv.Itoa(i)), dirFiles+id); err != nil {
+			return manifest, fmt.Errorf("file %s: %w", id, err)
+		}
+	}
+	if err = writeFile(tw, tmp.Name(), fileItems); err != nil {
+		return manifest, err
+	}
+
+	return manifest, tw.Close()
+}
+
+func header(name string, size int64, modTime time.Time) *tar.Header {
+	return &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+		Format:  tar.FormatPAX,
+	}
+}
+
+func writeJSON(tw *tar.Writer, name string, v interface{}) error {
+	bts, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(header(name, int64(len(bts)), time.Now().UTC())); err != nil {
+		return err
+	}
+	_, err = tw.Write(bts)
+	return err
+}
+
+// spoolFile скачивает файл id во временный файл path
+func spoolFile(ctx context.Context, fa *e365_gateway.FileAdapter, path, id string) error {
+	rc, err := fa.DownloadFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, rc); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFile записывает в архив под именем name содержимое временного файла path
+func writeFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(header(name, info.Size(), info.ModTime().UTC())); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// fileIDs возвращает id файлов из полей типа "Файл" (значение поля - id или массив id)
+func fileIDs(schema e365_gateway.AppSchema, item e365_gateway.DynamicItem) []string {
+	ids := make([]string, 0)
+	for _, f := range schema.Fields {
+		if f.Type != e365_gateway.FieldTypeFile {
+			continue
+		}
+		raw, ok := item.Raw(f.Code)
+		if !ok {
+			continue
+		}
+		var id string
+		if err := json.Unmarshal(raw, &id); err == nil {
+			if id != "" {
+				ids = append(ids, id)
+			}
+			continue
+		}
+		var many []string
+		if err := json.Unmarshal(raw, &many); err == nil {
+			ids = append(ids, many...)
+		}
+	}
+	return ids
+}
+
+// ReadManifest читает описание архива (manifest.json записывается первым, поэтому архив не читается целиком)
+func ReadManifest(r io.Reader) (Manifest, error) {
+	var m Manifest
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return m, fmt.Errorf("%w: %s not found", ErrInvalidArchive, fileManifest)
+		}
+		if err != nil {
+			return m, fmt.Errorf("%w: %s", ErrInvalidArchive, err)
+		}
+		if strings.TrimPrefix(h.Name, "./") != fileManifest {
+			continue
+		}
+		if err = json.NewDecoder(tr).Decode(&m); err != nil {
+			return m, fmt.Errorf("%w: %s: %s", ErrInvalidArchive, fileManifest, err)
+		}
+		return m, nil
+	}
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"github.com/inse91/elma_lib/internal/elmatest"
+	"github.com/stretchr/testify/require"
+)
+
+// newStand запускает имитацию стенда с приложением ref.goods и статусами new и done
+func newStand(t *testing.T, prefix string, statusNew, statusDone int) (*elmatest.Stand, *elmatest.App) {
+	st := elmatest.NewStand(t, prefix)
+	goods := st.App("ref", "goods")
+	goods.StatusInfo = map[string]interface{}{"statusItems": []map[string]interface{}{
+		{"id": statusNew, "code": "new"}, {"id": statusDone, "code": "done"},
+	}}
+	goods.Fields = []map[string]interface{}{
+		{"code": "__name", "type": "STRING"},
+		{"code": "__status", "type": "STATUS"},
+		{"code": "price", "type": "FLOAT"},
+		{"code": "parent", "type": "SYS_COLLECTION", "data": map[string]interface{}{"namespace": "ref", "code": "goods"}},
+		{"code": "supplier", "type": "SYS_COLLECTION", "data": map[string]interface{}{"namespace": "ref", "code": "suppliers"}},
+		{"code": "photo", "type": "FILE"},
+	}
+	return st, goods
+}
+
+func goodsApp(st *elmatest.Stand) e365_gateway.App[e365_gateway.DynamicItem] {
+	return e365_gateway.NewDynamicApp(e365_gateway.Settings{
+		Stand:     e365_gateway.NewStand(e365_gateway.StandConfig{Host: st.URL}),
+		Namespace: "ref",
+		Code:      "goods",
+	})
+}
+
+func fileAdapter(st *elmatest.Stand) e365_gateway.FileAdapter {
+	return e365_gateway.NewFileAdapter(e365_gateway.NewStand(e365_gateway.StandConfig{Host: st.URL}))
+}
+
+func TestBackup(t *testing.T) {
+
+	ctxBg := context.Background()
+	source, sourceGoods := newStand(t, "00000000-0000-4000-8000-", 1, 2)
+	source.Files["00000000-0000-4000-8000-f00000000100"] = "jpeg"
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second).Add(time.Millisecond)
+	created := createdAt.Format(time.RFC3339Nano)
+	child := source.Add(sourceGoods, map[string]interface{}{
+		"__name": "child", "__createdAt": created, "price": 10, "supplier": []string{"33333333-0000-4000-8000-000000000000"},
+		"photo": "00000000-0000-4000-8000-f00000000100", "__status": map[string]interface{}{"order": 0, "status": 2},
+	})
+	parent := source.Add(sourceGoods, map[string]interface{}{"__name": "parent", "__createdAt": created, "price": 20, "parent": nil})
+	source.Add(sourceGoods, map[string]interface{}{"__name": "deleted", "__createdAt": created, "__deletedAt": "2023-08-01T00:00:00Z"})
+	// файла нет на стенде: он записывается в MissingFiles и не прерывает Dump
+	source.Add(sourceGoods, map[string]interface{}{
+		"__name": "broken", "__createdAt": created, "__deletedAt": "2023-08-01T00:00:00Z",
+		"photo": "00000000-0000-4000-8000-f00000000200",
+	})
+	// элемент создается во время Dump (после определения границы снимка) и в архив не попадает,
+	// хотя локальное время создания архива позже
+	sourceGoods.OnList = func() {
+		sourceGoods.OnList = nil
+		source.Add(sourceGoods, map[string]interface{}{"__name": "later", "__createdAt": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)})
+	}
+	sourceGoods.Items[0]["parent"] = []string{parent}
+
+	buf := new(bytes.Buffer)
+	fa := fileAdapter(source)
+	manifest, err := Dump(ctxBg, goodsApp(source), buf, DumpOptions{Files: &fa})
+	require.NoError(t, err)
+	require.Equal(t, 4, manifest.Items)
+	require.Equal(t, 2, manifest.Deleted)
+	require.Equal(t, 1, manifest.Files)
+	require.Equal(t, []string{"00000000-0000-4000-8000-f00000000200"}, manifest.MissingFiles)
+	require.Equal(t, createdAt.Truncate(time.Second).Add(time.Second), manifest.Snapshot)
+	require.Equal(t, "goods", manifest.Code)
+
+	names := make([]string, 0)
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for h, err := tr.Next(); err == nil; h, err = tr.Next() {
+		names = append(names, h.Name)
+	}
+	require.Equal(t, []string{fileManifest, fileSchema, fileStatus, "files/00000000-0000-4000-8000-f00000000100", fileItems}, names)
+	read, err := ReadManifest(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, manifest, read)
+
+	target, targetGoods := newStand(t, "11111111-0000-4000-8000-", 5, 6)
+	dir := fileAdapter(target).NewDirectory("22222222-0000-4000-8000-000000000000")
+	report, err := Restore(ctxBg, bytes.NewReader(buf.Bytes()), goodsApp(target), RestoreOptions{Directory: &dir})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Created)
+	require.Equal(t, 2, report.Deleted)
+	require.Equal(t, 1, report.Files)
+	require.Len(t, targetGoods.Items, 2)
+
+	restored := targetGoods.Find(report.IDs[child])
+	require.Equal(t, "child", restored["__name"])
+	require.Equal(t, []interface{}{report.IDs[parent]}, restored["parent"])
+	require.Equal(t, []interface{}{"33333333-0000-4000-8000-000000000000"}, restored["supplier"])
+	require.Equal(t, map[string]interface{}{"order": float64(0), "status": float64(6)}, restored["__status"])
+	require.Equal(t, "00000000-0000-4000-8000-f00000000100:jpeg", target.Files[restored["photo"].(string)])
+	require.Equal(t, float64(20), targetGoods.Find(report.IDs[parent])["price"])
+
+	_, err = Restore(ctxBg, bytes.NewReader(buf.Bytes()), goodsApp(target), RestoreOptions{})
+	require.ErrorIs(t, err, ErrNotEmpty)
+
+	empty, _ := newStand(t, "44444444-0000-4000-8000-", 1, 2)
+	_, err = Restore(ctxBg, strings.NewReader("not a tar archive"), goodsApp(empty), RestoreOptions{})
+	require.ErrorIs(t, err, ErrInvalidArchive)
+}
@@ -0,0 +1,315 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	chunkSize          = 100
+	defaultConcurrency = 4
+)
+
+// RestoreOptions - параметры Restore
+type RestoreOptions struct {
+	// Directory - папка стенда приложения для файлов из архива. Если не задана, поля типа "Файл" не восстанавливаются
+	Directory *e365_gateway.Directory
+	// Concurrency - кол-во одновременных запросов к стенду (по умолчанию 4)
+	Concurrency int
+}
+
+// RestoreReport - итог восстановления
+type RestoreReport struct {
+	Manifest Manifest
+	Created  int
+	// Deleted - кол-во удаленных элементов архива, которые не восстанавливались
+	Deleted int
+	Files   int
+	// IDs - id элементов в архиве -> id созданных элементов (для замены ссылок в других приложениях)
+	IDs map[string]string
+}
+
+// restorer - состояние восстановления
+type restorer struct {
+	app    e365_gateway.App[e365_gateway.DynamicItem]
+	opts   RestoreOptions
+	schema *e365_gateway.AppSchema
+	// fields - коды восстанавливаемых полей: __name и поля приложения, кроме служебных
+	fields []string
+	// status - id статусов в архиве -> коды
+	status map[int]string
+	files  map[string]string
+	mu     sync.Mutex
+	report RestoreReport
+	// linked - созданные элементы со ссылками на элементы этого же приложения. Ссылки заполняются в конце,
+	// когда известны id всех элементов
+	linked []e365_gateway.DynamicItem
+}
+
+// Restore создает в пустом приложении элементы из архива, созданного Dump, и переводит их в статусы с теми же кодами.
+// Удаленные элементы не восстанавливаются. Элементы получают новые id, поэтому ссылки на элементы этого же
+// приложения заменяются новыми id, а ссылки на другие приложения и пользователей остаются как есть.
+// Служебные поля (автор, даты создания и изменения) заполняются стендом заново.
+// При ошибке восстановление прерывается, RestoreReport содержит уже созданные элементы
+func Restore(ctx context.Context, r io.Reader, app e365_gateway.App[e365_gateway.DynamicItem], opts RestoreOptions) (RestoreReport, error) {
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = defaultConcurrency
+	}
+	rs := &restorer{
+		app:    app,
+		opts:   opts,
+		files:  make(map[string]string),
+		report: RestoreReport{IDs: make(map[string]string)},
+	}
+
+	existing, err := app.Search().Size(1).All(ctx)
+	if err != nil {
+		return rs.report, err
+	}
+	if len(existing) > 0 {
+		return rs.report, ErrNotEmpty
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return rs.report, fmt.Errorf("%w: %s not found", ErrInvalidArchive, fileItems)
+		}
+		if err != nil {
+			return rs.report, fmt.Errorf("%w: %s", ErrInvalidArchive, err)
+		}
+
+		name := strings.TrimPrefix(h.Name, "./")
+		switch {
+		case name == fileManifest:
+			err = decode(tr, name, &rs.report.Manifest)
+		case name == fileSchema:
+			rs.schema = new(e365_gateway.AppSchema)
+			err = decode(tr, name, rs.schema)
+		case name == fileStatus:
+			var info e365_gateway.StatusInfo
+			if err = decode(tr, name, &info); err == nil {
+				rs.status = make(map[int]string, len(info.StatusItems))
+				for _, s := range info.StatusItems {
+					rs.status[s.Id] = s.Code
+				}
+			}
+		case strings.HasPrefix(name, dirFiles):
+			err = rs.upload(ctx, tr, strings.TrimPrefix(name, dirFiles))
+		case name == fileItems:
+			// items.jsonl - последний файл архива
+			return rs.report, rs.restore(ctx, tr)
+		}
+		if err != nil {
+			return rs.report, err
+		}
+	}
+}
+
+func decode(r io.Reader, name string, v interface{}) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
+
+// upload загружает файл архива в папку RestoreOptions.Directory
+func (rs *restorer) upload(ctx context.Context, r io.Reader, id string) error {
+	if rs.opts.Directory == nil {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, r); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		// пустой файл нельзя загрузить, ссылки на него не восстанавливаются
+		return nil
+	}
+	f, err := rs.opts.Directory.Upload(ctx, buf, id)
+	if err != nil {
+		return fmt.Errorf("file %s: %w", id, err)
+	}
+	rs.files[id] = f.ID
+	rs.report.Files++
+	return nil
+}
+
+// restore создает элементы из items.jsonl пачками по 100, а затем заполняет ссылки на элементы этого же приложения
+func (rs *restorer) restore(ctx context.Context, r io.Reader) error {
+
+	if rs.schema == nil {
+		return fmt.Errorf("%w: %s not found", ErrInvalidArchive, fileSchema)
+	}
+	rs.fields = []string{"__name"}
+	for _, f := range rs.schema.Fields {
+		if !strings.HasPrefix(f.Code, "__") && f.Type != e365_gateway.FieldTypeStatus {
+			rs.fields = append(rs.fields, f.Code)
+		}
+	}
+	info, err := rs.app.GetStatusInfo(ctx)
+	if err != nil {
+		return err
+	}
+	// коды статусов приложения -> id
+	target := make(map[string]int, len(info.StatusItems))
+	for _, s := range info.StatusItems {
+		target[s.Code] = s.Id
+	}
+
+	create := func(item e365_gateway.DynamicItem) error {
+		return rs.create(ctx, item, target)
+	}
+	dec := json.NewDecoder(r)
+	chunk := make([]e365_gateway.DynamicItem, 0, chunkSize)
+	for {
+		var item e365_gateway.DynamicItem
+		err := dec.Decode(&item)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidArchive, fileItems, err)
+		}
+		if !item.Common().DeletedAt.IsZero() {
+			rs.report.Deleted++
+			continue
+		}
+		if chunk = append(chunk, item); len(chunk) < chunkSize {
+			continue
+		}
+		if err = rs.each(ctx, chunk, create); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+	}
+	if err = rs.each(ctx, chunk, create); err != nil {
+		return err
+	}
+
+	return rs.each(ctx, rs.linked, func(item e365_gateway.DynamicItem) error {
+		return rs.link(ctx, item)
+	})
+}
+
+// each вызывает fn для элементов, не более чем Concurrency одновременно
+func (rs *restorer) each(ctx context.Context, items []e365_gateway.DynamicItem, fn func(item e365_gateway.DynamicItem) error) error {
+	eg := errgroup.Group{}
+	eg.SetLimit(rs.opts.Concurrency)
+	for _, item := range items {
+		item := item
+		eg.Go(func() error {
+			if err := fn(item); err != nil {
+				return fmt.Errorf("%s: %w", item.Common().ID, err)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// create создает элемент без ссылок на элементы этого же приложения и переводит его в статус с тем же кодом
+func (rs *restorer) create(ctx context.Context, item e365_gateway.DynamicItem, target map[string]int) error {
+
+	var body e365_gateway.DynamicItem
+	linked := false
+	for _, code := range rs.fields {
+		raw, ok := item.Raw(code)
+		if !ok {
+			continue
+		}
+		f, _ := rs.schema.Field(code)
+		var value interface{} = raw
+		switch {
+		case rs.self(f) && string(bytes.TrimSpace(raw)) != "null":
+			linked = true
+			continue
+		case f.Type == e365_gateway.FieldTypeFile:
+			if rs.opts.Directory == nil {
+				continue
+			}
+			value = rs.remap(raw, rs.files)
+		}
+		if err := body.Set(code, value); err != nil {
+			return err
+		}
+	}
+
+	created, err := rs.app.Create(ctx, body)
+	if err != nil {
+		return err
+	}
+	source := item.Common()
+	rs.mu.Lock()
+	rs.report.IDs[source.ID] = created.Common().ID
+	rs.report.Created++
+	if linked {
+		rs.linked = append(rs.linked, item)
+	}
+	rs.mu.Unlock()
+
+	code := rs.status[source.Status.Status]
+	if id, ok := target[code]; ok && id != created.Common().Status.Status {
+		if _, err = rs.app.SetStatus(ctx, created.Common().ID, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// link заполняет ссылки созданного элемента на элементы этого же приложения
+func (rs *restorer) link(ctx context.Context, item e365_gateway.DynamicItem) error {
+	var body e365_gateway.DynamicItem
+	for _, f := range rs.schema.Fields {
+		if raw, ok := item.Raw(f.Code); ok && rs.self(f) {
+			if err := body.Set(f.Code, rs.remap(raw, rs.report.IDs)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := rs.app.Update(ctx, rs.report.IDs[item.Common().ID], body)
+	return err
+}
+
+// self проверяет, ссылается ли поле на элементы этого же приложения
+func (rs *restorer) self(f e365_gateway.FieldSchema) bool {
+	return f.Type == e365_gateway.FieldTypeCollection && f.LinkedApp != nil &&
+		f.LinkedApp.Namespace == rs.schema.Namespace && f.LinkedApp.Code == rs.schema.Code
+}
+
+// remap заменяет id (или массив id) по ids. id, которых нет в ids, отбрасываются
+func (rs *restorer) remap(raw json.RawMessage, ids map[string]string) interface{} {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		if target, ok := ids[id]; ok {
+			return target
+		}
+		return nil
+	}
+	sources := make([]string, 0)
+	if err := json.Unmarshal(raw, &sources); err != nil {
+		return raw
+	}
+	targets := make([]string, 0, len(sources))
+	for _, id := range sources {
+		if target, ok := ids[id]; ok {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
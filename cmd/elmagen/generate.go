@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+const (
+	importGateway = "github.com/inse91/elma_lib"
+	importTypes   = "github.com/inse91/elma_lib/types"
+	importTime    = "time"
+	importJSON    = "encoding/json"
+)
+
+// goType возвращает тип поля в Go и пакет, который для него нужен
+func goType(f e365_gateway.FieldSchema) (string, string) {
+	switch f.Type {
+	case e365_gateway.FieldTypeString:
+		return "string", ""
+	case e365_gateway.FieldTypeInteger:
+		return "int", ""
+	case e365_gateway.FieldTypeFloat:
+		return "float64", ""
+	case e365_gateway.FieldTypeBoolean:
+		return "bool", ""
+	case e365_gateway.FieldTypeDateTime:
+		return "time.Time", importTime
+	case e365_gateway.FieldTypeMoney:
+		return "types.Money", importTypes
+	case e365_gateway.FieldTypeDuration:
+		return "types.Duration", importTypes
+	case e365_gateway.FieldTypeEnum, e365_gateway.FieldTypeCategory:
+		if f.Multiple {
+			return "types.Categories", importTypes
+		}
+		return "types.Category", importTypes
+	case e365_gateway.FieldTypeUser:
+		if f.Multiple {
+			return "types.UserRefs", importTypes
+		}
+		return "types.UserRef", importTypes
+	case e365_gateway.FieldTypePhone:
+		return "types.Phones", importTypes
+	case e365_gateway.FieldTypeEmail:
+		return "types.Emails", importTypes
+	case e365_gateway.FieldTypeFullName:
+		return "types.FullName", importTypes
+	case e365_gateway.FieldTypeCollection:
+		// тип связанного приложения неизвестен генератору, поэтому ссылки на DynamicItem
+		if f.Multiple {
+			return "e365_gateway.AppRefs[e365_gateway.DynamicItem]", importGateway
+		}
+		return "e365_gateway.AppRef[e365_gateway.DynamicItem]", importGateway
+	case e365_gateway.FieldTypeFile:
+		return "[]string", ""
+	}
+	return "json.RawMessage", importJSON
+}
+
+// identifier превращает код поля, статуса или приложения в экспортируемое имя Go: "due_date" -> "DueDate"
+func identifier(code string) string {
+	sb := strings.Builder{}
+	upper := true
+	for _, r := range code {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	id := sb.String()
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		id = "X" + id
+	}
+	for _, initialism := range []string{"Id", "Url", "Api"} {
+		if strings.HasSuffix(id, initialism) {
+			id = strings.TrimSuffix(id, initialism) + strings.ToUpper(initialism)
+		}
+	}
+	return id
+}
+
+// uniqueNames выдает имена без повторов, добавляя к повторяющимся номер
+type uniqueNames map[string]int
+
+func (u uniqueNames) name(base string) string {
+	name := base
+	for i := 2; u[name] > 0; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	u[name]++
+	return name
+}
+
+// comment возвращает комментарий в конце строки с названием из дизайнера
+func comment(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "\n", " "))
+	if name == "" {
+		return ""
+	}
+	return " // " + name
+}
+
+// generate возвращает отформатированный исходный код для приложения def
+func generate(def appDefinition, pkg, typeName string) ([]byte, error) {
+
+	schema := def.Schema
+	app := schema.Namespace + "." + schema.Code
+	if typeName == "" {
+		typeName = identifier(schema.Code)
+	}
+
+	imports := map[string]struct{}{importGateway: {}}
+	body := bytes.Buffer{}
+
+	// структура элемента
+	fmt.Fprintf(&body, "// %s - элемент приложения %s\n", typeName, app)
+	fmt.Fprintf(&body, "type %s struct {\n", typeName)
+	body.WriteString("e365_gateway.AppCommon\n")
+	fieldNames := uniqueNames{"AppCommon": 1}
+	fields := make([]e365_gateway.FieldSchema, 0, len(schema.Fields))
+	for _, f := range schema.Fields {
+		// служебные поля уже есть в AppCommon
+		if strings.HasPrefix(f.Code, "__") {
+			continue
+		}
+		typ, imp := goType(f)
+		if imp != "" {
+			imports[imp] = struct{}{}
+		}
+		fmt.Fprintf(&body, "%s %s `json:\"%s\"`%s\n", fieldNames.name(identifier(f.Code)), typ, f.Code, comment(f.Name))
+		fields = append(fields, f)
+	}
+	body.WriteString("}\n\n")
+
+	// коды полей
+	if len(fields) > 0 {
+		fmt.Fprintf(&body, "// Коды полей приложения %s (для Fields, Eq, OrderBy и т.д.)\n", app)
+		body.WriteString("const (\n")
+		constNames := uniqueNames{}
+		for _, f := range fields {
+			fmt.Fprintf(&body, "%sField%s = %q%s\n", typeName, constNames.name(identifier(f.Code)), f.Code, comment(f.Name))
+		}
+		body.WriteString(")\n\n")
+	}
+
+	// статусы и группы статусов: имена констант всех блоков не должны совпадать,
+	// например, id статуса new (…StatusNewID) и код статуса new_id
+	statusType := typeName + "Status"
+	groupType := typeName + "StatusGroup"
+	statusNames := uniqueNames{statusType: 1}
+	if len(def.Status.GroupItems) > 0 {
+		statusNames[groupType] = 1
+	}
+	if len(def.Status.StatusItems) > 0 {
+		names := make([]string, 0, len(def.Status.StatusItems))
+		ids := make([]string, 0, len(def.Status.StatusItems))
+		for _, s := range def.Status.StatusItems {
+			name := statusNames.name(statusType + identifier(s.Code))
+			names = append(names, name)
+			ids = append(ids, statusNames.name(name+"ID"))
+		}
+
+		fmt.Fprintf(&body, "// %s - код статуса приложения %s (для App.SetStatus)\n", statusType, app)
+		fmt.Fprintf(&body, "type %s string\n\n", statusType)
+		body.WriteString("const (\n")
+		for i, s := range def.Status.StatusItems {
+			fmt.Fprintf(&body, "%s %s = %q%s\n", names[i], statusType, s.Code, comment(s.Name))
+		}
+		body.WriteString(")\n\n")
+
+		body.WriteString("// Id статусов (значение AppCommon.Status.Status)\n")
+		body.WriteString("const (\n")
+		for i, s := range def.Status.StatusItems {
+			fmt.Fprintf(&body, "%s = %d\n", ids[i], s.Id)
+		}
+		body.WriteString(")\n\n")
+	}
+	if len(def.Status.GroupItems) > 0 {
+		fmt.Fprintf(&body, "// %s - код группы статусов приложения %s\n", groupType, app)
+		fmt.Fprintf(&body, "type %s string\n\n", groupType)
+		body.WriteString("const (\n")
+		for _, g := range def.Status.GroupItems {
+			fmt.Fprintf(&body, "%s %s = %q%s\n", statusNames.name(groupType+identifier(g.Code)), groupType, g.Code, comment(g.Name))
+		}
+		body.WriteString(")\n")
+	}
+
+	paths := make([]string, 0, len(imports))
+	for imp := range imports {
+		paths = append(paths, imp)
+	}
+	sort.Strings(paths)
+
+	src := bytes.Buffer{}
+	src.WriteString("// Code generated by elmagen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	src.WriteString("import (\n")
+	for _, imp := range paths {
+		if imp == importGateway {
+			fmt.Fprintf(&src, "e365_gateway %q\n", imp)
+			continue
+		}
+		fmt.Fprintf(&src, "%q\n", imp)
+	}
+	src.WriteString(")\n\n")
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDefinition = `{
+	"schema": {
+		"namespace": "goods",
+		"code": "orders",
+		"fields": [
+			{"code": "__name", "type": "STRING", "name": "Название"},
+			{"code": "title", "type": "STRING", "name": "Заголовок"},
+			{"code": "due_date", "type": "DATETIME"},
+			{"code": "total", "type": "MONEY"},
+			{"code": "qty", "type": "INTEGER"},
+			{"code": "kind", "type": "ENUM", "values": [{"code": "big", "name": "Большой"}]},
+			{"code": "tags", "type": "ENUM", "multiple": true},
+			{"code": "section", "type": "CATEGORY"},
+			{"code": "product_id", "type": "SYS_COLLECTION", "linkedApp": {"namespace": "goods", "code": "products"}},
+			{"code": "payload", "type": "JSON"}
+		]
+	},
+	"status": {
+		"statusItems": [
+			{"id": 1, "code": "new", "name": "Новый", "groupId": "g1"},
+			{"id": 2, "code": "in_work", "name": "В работе", "groupId": "g1"}
+		],
+		"groupItems": [{"id": "g1", "code": "open", "name": "Открытые"}]
+	}
+}`
+
+func TestGenerate(t *testing.T) {
+
+	var def appDefinition
+	require.NoError(t, json.Unmarshal([]byte(testDefinition), &def))
+
+	src, err := generate(def, "goods", "")
+	require.NoError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "orders_gen.go", src, parser.AllErrors)
+	require.NoError(t, err, string(src))
+
+	code := string(src)
+	for _, expected := range []string{
+		"// Code generated by elmagen; DO NOT EDIT.",
+		"package goods",
+		"e365_gateway \"github.com/inse91/elma_lib\"",
+		"\"github.com/inse91/elma_lib/types\"",
+		"type Orders struct {\n\te365_gateway.AppCommon\n",
+		"`json:\"title\"` // Заголовок",
+		"DueDate   time.Time ",
+		"Total     types.Money ",
+		"Kind      types.Category ",
+		"Tags      types.Categories ",
+		"Section   types.Category ",
+		"ProductID e365_gateway.AppRef[e365_gateway.DynamicItem] ",
+		"Payload   json.RawMessage ",
+		"OrdersFieldDueDate   = \"due_date\"",
+		"type OrdersStatus string",
+		"OrdersStatusInWork OrdersStatus = \"in_work\" // В работе",
+		"OrdersStatusInWorkID = 2",
+		"OrdersStatusGroupOpen OrdersStatusGroup = \"open\"",
+	} {
+		require.Contains(t, code, expected)
+	}
+	require.NotContains(t, code, "__name")
+
+}
+
+func TestGenerateStatusNames(t *testing.T) {
+
+	var def appDefinition
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"schema": {"namespace": "goods", "code": "orders", "fields": []},
+		"status": {
+			"statusItems": [
+				{"id": 1, "code": "new_id"},
+				{"id": 2, "code": "new"},
+				{"id": 3, "code": "group_open"}
+			],
+			"groupItems": [{"id": "g1", "code": "open"}]
+		}
+	}`), &def))
+
+	src, err := generate(def, "goods", "")
+	require.NoError(t, err)
+	file, err := parser.ParseFile(token.NewFileSet(), "orders_gen.go", src, parser.AllErrors)
+	require.NoError(t, err, string(src))
+
+	// все объявления пакета должны иметь разные имена, иначе файл не скомпилируется
+	names := make(map[string]struct{})
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				require.NotContains(t, names, spec.Name.Name, string(src))
+				names[spec.Name.Name] = struct{}{}
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					require.NotContains(t, names, name.Name, string(src))
+					names[name.Name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// выравнивание gofmt не учитывается
+	code := strings.Join(strings.Fields(string(src)), " ")
+	for _, expected := range []string{
+		"OrdersStatusNewID OrdersStatus = \"new_id\"",
+		"OrdersStatusNewIDID = 1",
+		"OrdersStatusNew OrdersStatus = \"new\"",
+		"OrdersStatusNewID2 = 2",
+		"OrdersStatusGroupOpen2 OrdersStatusGroup = \"open\"",
+	} {
+		require.Contains(t, code, expected)
+	}
+
+}
+
+func TestIdentifier(t *testing.T) {
+	for code, expected := range map[string]string{
+		"title":      "Title",
+		"due_date":   "DueDate",
+		"clientId":   "ClientID",
+		"2nd_line":   "X2ndLine",
+		"total-cost": "TotalCost",
+	} {
+		require.Equal(t, expected, identifier(code), code)
+	}
+}
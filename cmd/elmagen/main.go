@@ -0,0 +1,116 @@
+// elmagen генерирует контекст приложения elma365 для NewApp[T]: структуру со встроенным AppCommon,
+// константы кодов полей (для Fields) и типизированные константы кодов статусов.
+//
+// Описание приложения читается со стенда:
+//
+//	elmagen -host https://xxx.elma365.ru -token TOKEN -ns goods -code orders -pkg goods -out orders_gen.go
+//
+// или из файла, сохраненного ранее через -dump (для сборки без доступа к стенду):
+//
+//	elmagen -host https://xxx.elma365.ru -token TOKEN -ns goods -code orders -dump orders.json
+//	elmagen -schema orders.json -pkg goods -out orders_gen.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "elmagen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+
+	var (
+		host     = flag.String("host", "", "адрес стенда elma365")
+		port     = flag.String("port", "", "порт стенда")
+		token    = flag.String("token", os.Getenv("ELMA365_TOKEN"), "токен доступа (по умолчанию $ELMA365_TOKEN)")
+		ns       = flag.String("ns", "", "код раздела")
+		code     = flag.String("code", "", "код приложения")
+		schema   = flag.String("schema", "", "файл с описанием приложения (вместо стенда)")
+		dump     = flag.String("dump", "", "сохранить описание приложения в файл и завершить работу")
+		pkg      = flag.String("pkg", "main", "пакет сгенерированного файла")
+		typeName = flag.String("type", "", "имя структуры (по умолчанию из кода приложения)")
+		out      = flag.String("out", "", "файл для записи (по умолчанию stdout)")
+	)
+	flag.Parse()
+
+	var def appDefinition
+	if *schema != "" {
+		bts, err := os.ReadFile(*schema)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(bts, &def); err != nil {
+			return fmt.Errorf("decode %s: %w", *schema, err)
+		}
+	} else {
+		if *host == "" || *ns == "" || *code == "" {
+			flag.Usage()
+			return fmt.Errorf("-host, -ns and -code are required without -schema")
+		}
+		var err error
+		def, err = fetchDefinition(e365_gateway.Settings{
+			Stand:     e365_gateway.NewStand(e365_gateway.StandConfig{Host: *host, Port: *port, Token: *token}),
+			Namespace: *ns,
+			Code:      *code,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *dump != "" {
+		bts, err := json.MarshalIndent(def, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*dump, bts, 0o644)
+	}
+
+	src, err := generate(def, *pkg, *typeName)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+
+}
+
+// appDefinition - описание приложения, по которому генерируется код (формат файла -schema)
+type appDefinition struct {
+	Schema e365_gateway.AppSchema  `json:"schema"`
+	Status e365_gateway.StatusInfo `json:"status"`
+}
+
+func fetchDefinition(settings e365_gateway.Settings) (appDefinition, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	app := e365_gateway.NewDynamicApp(settings)
+	schema, err := app.Schema(ctx)
+	if err != nil {
+		return appDefinition{}, err
+	}
+	status, err := app.GetStatusInfo(ctx)
+	if err != nil {
+		return appDefinition{}, err
+	}
+
+	return appDefinition{Schema: schema, Status: status}, nil
+
+}
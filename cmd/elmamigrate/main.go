@@ -0,0 +1,122 @@
+// elmamigrate переносит элементы приложений elma365 между стендами (см. пакет migrate).
+//
+// Приложения задаются флагом -app в виде namespace.code:key, где key - поле для сопоставления элементов:
+//
+//	elmamigrate -from https://dev.elma365.ru -to https://test.elma365.ru \
+//		-app ref.categories:external_id -app ref.goods:external_id -dir DIRECTORY_ID
+//
+// План переноса сохраняется в файл -plan. Если файл уже есть, перенос продолжается по нему:
+// повторяются только невыполненные элементы. План должен быть создан для тех же -app. С -dry-run план только создается и выводится итог,
+// файл плана не записывается.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strings"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"github.com/inse91/elma_lib/migrate"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "elmamigrate:", err)
+		os.Exit(1)
+	}
+}
+
+// appFlags - значения повторяемого флага -app
+type appFlags []migrate.AppSpec
+
+func (f *appFlags) String() string {
+	names := make([]string, 0, len(*f))
+	for _, spec := range *f {
+		names = append(names, spec.Namespace+"."+spec.Code+":"+spec.Key)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *appFlags) Set(value string) error {
+	app, key, _ := strings.Cut(value, ":")
+	ns, code, ok := strings.Cut(app, ".")
+	if !ok || ns == "" || code == "" || key == "" {
+		return fmt.Errorf("expected namespace.code:key, got %q", value)
+	}
+	*f = append(*f, migrate.AppSpec{Namespace: ns, Code: code, Key: key})
+	return nil
+}
+
+func run() error {
+
+	var apps appFlags
+	var (
+		from        = flag.String("from", "", "адрес исходного стенда")
+		fromPort    = flag.String("from-port", "", "порт исходного стенда")
+		fromToken   = flag.String("from-token", os.Getenv("ELMA365_FROM_TOKEN"), "токен исходного стенда (по умолчанию $ELMA365_FROM_TOKEN)")
+		to          = flag.String("to", "", "адрес целевого стенда")
+		toPort      = flag.String("to-port", "", "порт целевого стенда")
+		toToken     = flag.String("to-token", os.Getenv("ELMA365_TO_TOKEN"), "токен целевого стенда (по умолчанию $ELMA365_TO_TOKEN)")
+		dir         = flag.String("dir", "", "id папки целевого стенда для файлов (без нее файлы не переносятся)")
+		planPath    = flag.String("plan", "migrate-plan.json", "файл плана переноса")
+		dryRun      = flag.Bool("dry-run", false, "только создать план и вывести итог")
+		concurrency = flag.Int("concurrency", 0, "кол-во одновременных запросов к целевому стенду")
+	)
+	flag.Var(&apps, "app", "приложение в виде namespace.code:key (можно указать несколько раз)")
+	flag.Parse()
+
+	if *from == "" || *to == "" || len(apps) == 0 {
+		flag.Usage()
+		return fmt.Errorf("-from, -to and -app are required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	m := migrate.New(
+		e365_gateway.NewStand(e365_gateway.StandConfig{Host: *from, Port: *fromPort, Token: *fromToken}),
+		e365_gateway.NewStand(e365_gateway.StandConfig{Host: *to, Port: *toPort, Token: *toToken}),
+		migrate.Options{Apps: apps, Directory: *dir, Concurrency: *concurrency},
+	)
+
+	plan, err := migrate.LoadPlan(*planPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if plan, err = m.Plan(ctx); err != nil {
+			return err
+		}
+		if !*dryRun {
+			if err = migrate.SavePlan(*planPath, plan); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	} else if err = plan.Check(apps); err != nil {
+		return fmt.Errorf("%s: %w (remove the file to create a new plan)", *planPath, err)
+	}
+
+	report := plan.Report()
+	if !*dryRun {
+		report, err = m.Apply(ctx, &plan, func(p migrate.Plan) error {
+			return migrate.SavePlan(*planPath, p)
+		})
+	}
+	printReport(report)
+	return err
+
+}
+
+func printReport(r migrate.Report) {
+	for _, ar := range r.Apps {
+		fmt.Printf("%s: created %d, updated %d, skipped %d, pending %d\n", ar.App, ar.Created, ar.Updated, ar.Skipped, ar.Pending)
+		for _, e := range ar.Errors {
+			fmt.Println("\t" + e)
+		}
+	}
+	fmt.Printf("files: %d\n", r.Files)
+}
@@ -19,7 +19,23 @@ var (
 	ErrEmptyBuffer        = errors.New("item is nil")
 	ErrNilSearchFilter    = errors.New("search filter is nil")
 	ErrResponseNilItem    = errors.New("response item in nil")
+	ErrItemNotFound       = errors.New("item not found")
 	ErrNoMoreItems        = errors.New("no more items")
+	ErrInvalidScanRange   = errors.New("invalid scan range")
+	ErrScanUnsupported    = errors.New("item has no __id, __createdAt or __updatedAt field")
+	ErrUnknownField       = errors.New("unknown item field")
+	ErrFieldType          = errors.New("filter value does not match field type")
+	ErrSubqueryLimit      = errors.New("subquery returned too many items")
+	ErrUnresolvedSubquery = errors.New("subquery is not resolved")
+	ErrSubqueryChunks     = errors.New("only one subquery may be split into chunks")
+	ErrSchemaMismatch     = errors.New("item type does not match app schema")
+	ErrUnknownStatus      = errors.New("unknown status")
+	ErrUnsupportedFormat  = errors.New("unsupported format")
+	ErrExportItem         = errors.New("failed converting item for export")
+	ErrImportValue        = errors.New("invalid import value")
+	ErrDuplicateKey       = errors.New("duplicate import key")
+	ErrAmbiguousKey       = errors.New("key matches several items")
+	ErrNoCheckpointStore  = errors.New("checkpoint store is not set")
 
 	ErrCreateFormData       = errors.New("failed creating form data")
 	ErrWriteBytesBuffer     = errors.New("failed writing from bytes buffer to form data")
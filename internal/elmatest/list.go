@@ -0,0 +1,206 @@
+package elmatest
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// list возвращает страницу элементов, подходящих под тело запроса /list, и их общее кол-во
+func (app *App) list(body map[string]interface{}) ([]map[string]interface{}, int) {
+
+	matched := make([]map[string]interface{}, 0)
+	active, _ := body["active"].(bool)
+	ids, _ := body["ids"].([]interface{})
+	expr, _ := body["filter"].(map[string]interface{})
+
+	for _, item := range app.Items {
+		if active && item["__deletedAt"] != nil {
+			continue
+		}
+		if len(ids) > 0 && !containsValue(ids, item["__id"]) {
+			continue
+		}
+		if !matchExpr(item, expr) {
+			continue
+		}
+		if text, _ := body["searchString"].(string); text != "" && !matchText(item, text) {
+			continue
+		}
+		if codes, _ := body["statusCode"].([]interface{}); len(codes) > 0 && !containsValue(codes, app.statusCode(item)) {
+			continue
+		}
+		matched = append(matched, item)
+	}
+
+	sorts, _ := body["sortExpressions"].([]interface{})
+	sort.SliceStable(matched, func(i, j int) bool {
+		for _, se := range sorts {
+			se := se.(map[string]interface{})
+			field := se["field"].(string)
+			c := compareValues(matched[i][field], matched[j][field])
+			if c == 0 {
+				continue
+			}
+			if se["ascending"] == true {
+				return c < 0
+			}
+			return c > 0
+		}
+		return false
+	})
+
+	total := len(matched)
+	from, _ := body["from"].(float64)
+	size, _ := body["size"].(float64)
+	if int(from) >= len(matched) {
+		return []map[string]interface{}{}, total
+	}
+	matched = matched[int(from):]
+	if int(size) < len(matched) {
+		matched = matched[:int(size)]
+	}
+
+	if fields, ok := body["fields"].(map[string]interface{}); ok && fields["*"] == false {
+		projected := make([]map[string]interface{}, 0, len(matched))
+		for _, item := range matched {
+			p := make(map[string]interface{}, len(fields))
+			for k := range fields {
+				if v, ok := item[k]; ok && fields[k] == true {
+					p[k] = v
+				}
+			}
+			projected = append(projected, p)
+		}
+		matched = projected
+	}
+	return matched, total
+}
+
+// matchExpr проверяет элемент на соответствие фильтру: tf, and, or, not, eq, like, in, link
+func matchExpr(item map[string]interface{}, expr map[string]interface{}) bool {
+	for op, arg := range expr {
+		var ok bool
+		switch op {
+		case "tf":
+			tf, _ := arg.(map[string]interface{})
+			ok = matchTf(item, tf)
+		case "and", "or":
+			ok = op == "and"
+			for _, sub := range arg.([]interface{}) {
+				if matchExpr(item, sub.(map[string]interface{})) != ok {
+					ok = !ok
+					break
+				}
+			}
+		case "not":
+			ok = !matchExpr(item, arg.(map[string]interface{}))
+		default:
+			args := arg.([]interface{})
+			v := item[args[0].(map[string]interface{})["field"].(string)]
+			operand := args[1].(map[string]interface{})
+			values, isList := v.([]interface{})
+			if !isList {
+				values = []interface{}{v}
+			}
+			switch op {
+			case "eq":
+				ok = compareValues(v, operand["const"]) == 0 && (v == nil) == (operand["const"] == nil)
+			case "like":
+				s, _ := v.(string)
+				ok = strings.Contains(strings.ToLower(s), strings.ToLower(strings.Trim(operand["const"].(string), "%")))
+			case "in", "link":
+				for _, lv := range operand["list"].([]interface{}) {
+					ok = ok || containsValue(values, lv)
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTf(item map[string]interface{}, tf map[string]interface{}) bool {
+	for k, cond := range tf {
+		v := item[k]
+		switch c := cond.(type) {
+		case map[string]interface{}:
+			if min, ok := c["min"]; ok && compareValues(v, min) < 0 {
+				return false
+			}
+			if max, ok := c["max"]; ok && compareValues(v, max) > 0 {
+				return false
+			}
+		case []interface{}:
+			values, _ := v.([]interface{})
+			found := false
+			for _, cv := range c {
+				found = found || containsValue(values, cv)
+			}
+			if !found {
+				return false
+			}
+		default:
+			if compareValues(v, c) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchText(item map[string]interface{}, text string) bool {
+	for _, v := range item {
+		if s, ok := v.(string); ok && strings.Contains(strings.ToLower(s), strings.ToLower(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if compareValues(value, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues сравнивает числа, даты (в формате RFC3339) и строки
+func compareValues(a, b interface{}) int {
+	if af, ok := number(a); ok {
+		bf, _ := number(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	as, _ := a.(string)
+	bs, _ := b.(string)
+	if as == bs || len(as) < 20 || as[10] != 'T' {
+		return strings.Compare(as, bs)
+	}
+	if at, err := time.Parse(time.RFC3339, as); err == nil {
+		if bt, err := time.Parse(time.RFC3339, bs); err == nil {
+			return at.Compare(bt)
+		}
+	}
+	return strings.Compare(as, bs)
+}
+
+// number возвращает число из значения, полученного из JSON (float64) или заданного в тесте (int)
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,295 @@
+// Package elmatest - имитация стенда elma365 для тестов пакетов библиотеки, которым не нужен настоящий стенд.
+//
+// Stand поддерживает методы приложений /list (filter, ids, sortExpressions, from, size, active, fields, searchString,
+// statusCode), /get, /create, /update, /set-status, /settings/status и /settings/fields, а также получение ссылки на файл,
+// скачивание и загрузку файлов в папку
+package elmatest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// DefaultPrefix - начало id элементов, которые создает стенд без заданного префикса (как у ID)
+	DefaultPrefix = "00000000-0000-4000-8000-"
+
+	appPrefix  = "/pub/v1/app/"
+	filePrefix = "/pub/v1/disk/file/"
+	dirPrefix  = "/pub/v1/disk/directory/"
+	download   = "/download/"
+)
+
+// Stand - имитация стенда. Приложения создаются при первом обращении (App или запрос к серверу).
+// Все запросы выполняются под блокировкой стенда: чтобы изменить элементы во время теста, вызовите Lock и Unlock
+type Stand struct {
+	sync.Mutex
+	// URL - адрес стенда для StandConfig.Host
+	URL string
+	// Files - содержимое файлов по id. Загруженные файлы сохраняются как "имя:содержимое"
+	Files  map[string]string
+	prefix string
+	apps   map[string]*App
+}
+
+// App - приложение стенда
+type App struct {
+	Items []map[string]interface{}
+	// StatusInfo - ответ /settings/status (например, e365_gateway.StatusInfo). По нему же /set-status находит id статуса
+	StatusInfo interface{}
+	// Fields - ответ /settings/fields
+	Fields []map[string]interface{}
+	// Lists - кол-во запросов /list, Bodies - их тела
+	Lists  int
+	Bodies []map[string]interface{}
+	// Hits - кол-во обращений к каждому методу по последнему сегменту пути, например "/list"
+	Hits map[string]int
+	// Writes - кол-во запросов /create и /update
+	Writes int
+	// OnList вызывается после каждого /list (под блокировкой стенда), например, чтобы изменить элементы между страницами
+	OnList func()
+	// Fail - если возвращает true для тела /create или /update, стенд отвечает ошибкой 500
+	Fail func(ctx map[string]interface{}) bool
+}
+
+// NewStand запускает стенд, который останавливается по завершении теста.
+// prefix - начало id создаваемых элементов и файлов (по умолчанию DefaultPrefix)
+func NewStand(t testing.TB, prefix string) *Stand {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	st := &Stand{
+		Files:  make(map[string]string),
+		prefix: prefix,
+		apps:   make(map[string]*App),
+	}
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+	st.URL = srv.URL
+	return st
+}
+
+// App возвращает приложение namespace.code
+func (st *Stand) App(namespace, code string) *App {
+	key := namespace + "." + code
+	app, ok := st.apps[key]
+	if !ok {
+		app = &App{Hits: make(map[string]int)}
+		st.apps[key] = app
+	}
+	return app
+}
+
+// Add добавляет элемент в приложение. Если у элемента нет __id, он получает новый id стенда
+func (st *Stand) Add(app *App, item map[string]interface{}) string {
+	if _, ok := item["__id"]; !ok {
+		item["__id"] = st.newID()
+	}
+	app.Items = append(app.Items, item)
+	return item["__id"].(string)
+}
+
+// newID возвращает id с префиксом стенда и номером после всех элементов стенда
+func (st *Stand) newID() string {
+	n := 10000
+	for _, app := range st.apps {
+		n += len(app.Items)
+	}
+	return id(st.prefix, n)
+}
+
+// Find возвращает элемент приложения по id
+func (app *App) Find(id string) map[string]interface{} {
+	for _, item := range app.Items {
+		if item["__id"] == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// ID возвращает uuid с порядковым номером n
+func ID(n int) string {
+	return id(DefaultPrefix, n)
+}
+
+func id(prefix string, n int) string {
+	s := strings.Repeat("0", 12) + strconv.Itoa(n)
+	return prefix + s[len(s)-12:]
+}
+
+// Item создает элемент с id, названием "item", версией 1 и датами создания и изменения createdAt
+func Item(id string, createdAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"__id":        id,
+		"__name":      "item",
+		"__createdAt": createdAt.UTC().Format(time.RFC3339Nano),
+		"__updatedAt": createdAt.UTC().Format(time.RFC3339Nano),
+		"__version":   float64(1),
+	}
+}
+
+func (st *Stand) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st.Lock()
+	defer st.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, download):
+		_, _ = io.WriteString(w, st.Files[strings.TrimPrefix(path, download)])
+		return
+	case strings.HasPrefix(path, filePrefix):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, filePrefix), "/get-link")
+		if _, ok := st.Files[id]; !ok {
+			reply(w, http.StatusNotFound, map[string]interface{}{"success": false, "error": "file not found"})
+			return
+		}
+		reply(w, http.StatusOK, map[string]interface{}{"success": true, "Link": st.URL + download + id})
+		return
+	case strings.HasPrefix(path, dirPrefix):
+		_ = r.ParseMultipartForm(1 << 20)
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			reply(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		content, _ := io.ReadAll(f)
+		id := st.prefix + "f" + strconv.Itoa(1000000000 + len(st.Files))[1:] + "00"
+		st.Files[id] = header.Filename + ":" + string(content)
+		reply(w, http.StatusOK, map[string]interface{}{"success": true, "file": map[string]interface{}{"__id": id}})
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(path, appPrefix), "/", 3)
+	if !strings.HasPrefix(path, appPrefix) || len(parts) < 3 {
+		reply(w, http.StatusNotFound, map[string]interface{}{"success": false, "error": "unknown method " + path})
+		return
+	}
+	app := st.App(parts[0], parts[1])
+	method := "/" + parts[2]
+	app.Hits[method[strings.LastIndex(method, "/"):]]++
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	ctx, _ := body["context"].(map[string]interface{})
+
+	switch {
+	case method == "/list":
+		app.Lists++
+		app.Bodies = append(app.Bodies, body)
+		result, total := app.list(body)
+		if app.OnList != nil {
+			app.OnList()
+		}
+		reply(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"result":  map[string]interface{}{"result": result, "total": total},
+		})
+	case method == "/settings/status":
+		resp := map[string]interface{}{}
+		if bts, err := json.Marshal(app.StatusInfo); err == nil {
+			_ = json.Unmarshal(bts, &resp)
+		}
+		resp["success"] = true
+		reply(w, http.StatusOK, resp)
+	case method == "/settings/fields":
+		reply(w, http.StatusOK, map[string]interface{}{"success": true, "fields": app.Fields})
+	case method == "/create":
+		app.Writes++
+		if app.Fail != nil && app.Fail(ctx) {
+			reply(w, http.StatusInternalServerError, map[string]interface{}{"success": false, "error": "failed"})
+			return
+		}
+		item := Item(st.newID(), time.Now())
+		if statuses := app.statuses(); len(statuses) > 0 {
+			item["__status"] = map[string]interface{}{"order": float64(0), "status": float64(statuses[0].ID)}
+		}
+		for k, v := range ctx {
+			if !strings.HasPrefix(k, "__") || k == "__name" {
+				item[k] = v
+			}
+		}
+		app.Items = append(app.Items, item)
+		reply(w, http.StatusOK, map[string]interface{}{"success": true, "item": item})
+	default:
+		id := strings.Trim(method[:strings.LastIndex(method, "/")], "/")
+		item := app.Find(id)
+		if item == nil {
+			reply(w, http.StatusNotFound, map[string]interface{}{"success": false, "error": "not found"})
+			return
+		}
+		switch {
+		case strings.HasSuffix(method, "/get"):
+			reply(w, http.StatusOK, map[string]interface{}{"success": true, "item": item})
+		case strings.HasSuffix(method, "/update"):
+			app.Writes++
+			if app.Fail != nil && app.Fail(ctx) {
+				reply(w, http.StatusInternalServerError, map[string]interface{}{"success": false, "error": "failed"})
+				return
+			}
+			for k, v := range ctx {
+				if !strings.HasPrefix(k, "__") || k == "__name" {
+					item[k] = v
+				}
+			}
+			version, _ := item["__version"].(float64)
+			item["__version"] = version + 1
+			item["__updatedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+			reply(w, http.StatusOK, map[string]interface{}{"success": true, "item": item})
+		case strings.HasSuffix(method, "/set-status"):
+			status, _ := body["status"].(map[string]interface{})
+			for _, s := range app.statuses() {
+				if s.Code == status["code"] {
+					item["__status"] = map[string]interface{}{"order": float64(0), "status": float64(s.ID)}
+					reply(w, http.StatusOK, map[string]interface{}{"success": true, "item": item})
+					return
+				}
+			}
+			reply(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": "bad request"})
+		default:
+			reply(w, http.StatusNotFound, map[string]interface{}{"success": false, "error": "unknown method " + path})
+		}
+	}
+}
+
+func reply(w http.ResponseWriter, code int, v interface{}) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type status struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+}
+
+// statuses возвращает статусы приложения из StatusInfo
+func (app *App) statuses() []status {
+	var info struct {
+		StatusItems []status `json:"statusItems"`
+	}
+	if bts, err := json.Marshal(app.StatusInfo); err == nil {
+		_ = json.Unmarshal(bts, &info)
+	}
+	return info.StatusItems
+}
+
+// statusCode возвращает код текущего статуса элемента
+func (app *App) statusCode(item map[string]interface{}) string {
+	s, _ := item["__status"].(map[string]interface{})
+	id, _ := number(s["status"])
+	for _, s := range app.statuses() {
+		if float64(s.ID) == id {
+			return s.Code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,567 @@
+// Package migrate переносит элементы приложений elma365 между стендами (например, справочники с dev на test и prod).
+//
+// Перенос выполняется в два шага. Plan сопоставляет элементы исходного и целевого стендов по ключевому полю
+// (например, внешнему id) и определяет, какие элементы будут созданы, а какие обновлены. Apply выполняет план:
+// переносит значения полей, заменяет ссылки на перенесенные элементы id целевого стенда, загружает файлы
+// в папку целевого стенда и переводит элементы в статусы с теми же кодами.
+//
+// Apply отмечает выполненные элементы в плане и сохраняет его после каждой пачки, поэтому прерванный перенос
+// можно продолжить, загрузив план через LoadPlan. Элементы, созданные после последнего сохранения плана, Apply находит
+// на целевом стенде по ключевому полю и не создает повторно. Ссылки на элементы, которые еще не были перенесены
+// (например, при взаимных ссылках приложений), заполняются в конце Apply.
+//
+// Поля со ссылками на приложения, которые не участвуют в переносе, не переносятся: id элементов исходного стенда
+// на целевом стенде не существуют. Поля типа "Пользователи" и таблицы переносятся как есть.
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	chunkSize          = 100
+	defaultConcurrency = 4
+)
+
+var ErrNoKey = errors.New("app key field is not set")
+
+// AppSpec - приложение, элементы которого переносятся
+type AppSpec struct {
+	Namespace string
+	Code      string
+	// Key - код поля, по которому элементы сопоставляются на стендах (например, внешний id или __name). Обязательный
+	Key string
+	// Filter - фильтр переносимых элементов исходного стенда
+	Filter e365_gateway.SearchFilter
+	// Fields - коды переносимых полей (по умолчанию все поля приложения, кроме служебных, и __name)
+	Fields []string
+}
+
+// Options - параметры переноса
+type Options struct {
+	// Apps - переносимые приложения. Приложения, на которые ссылаются другие, лучше указывать раньше:
+	// тогда ссылки заполняются сразу, без повторного изменения элементов в конце Apply
+	Apps []AppSpec
+	// Directory - id папки целевого стенда для файлов. Если не задана, поля типа "Файл" не переносятся
+	Directory string
+	// Concurrency - кол-во одновременных запросов к целевому стенду (по умолчанию 4)
+	Concurrency int
+}
+
+// Migrator - перенос элементов приложений со стенда source на стенд target
+type Migrator struct {
+	source e365_gateway.Stand
+	target e365_gateway.Stand
+	opts   Options
+	files  e365_gateway.FileAdapter
+	dir    e365_gateway.Directory
+}
+
+// New создает перенос элементов приложений со стенда source на стенд target
+func New(source, target e365_gateway.Stand, opts Options) *Migrator {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = defaultConcurrency
+	}
+	return &Migrator{
+		source: source,
+		target: target,
+		opts:   opts,
+		files:  e365_gateway.NewFileAdapter(source),
+		dir:    e365_gateway.NewFileAdapter(target).NewDirectory(opts.Directory),
+	}
+}
+
+func (m *Migrator) app(stand e365_gateway.Stand, spec AppSpec) e365_gateway.App[e365_gateway.DynamicItem] {
+	return e365_gateway.NewDynamicApp(e365_gateway.Settings{Stand: stand, Namespace: spec.Namespace, Code: spec.Code})
+}
+
+// spec возвращает параметры приложения из плана (приложение могло быть исключено из Options после создания плана)
+func (m *Migrator) spec(ap AppPlan) AppSpec {
+	for _, spec := range m.opts.Apps {
+		if spec.Namespace == ap.Namespace && spec.Code == ap.Code {
+			return spec
+		}
+	}
+	return AppSpec{Namespace: ap.Namespace, Code: ap.Code, Key: ap.Key}
+}
+
+// Plan сопоставляет элементы исходного и целевого стендов по ключевому полю. Элементы без ключа,
+// с повторяющимся ключом или с ключом, которому на целевом стенде соответствует несколько элементов, пропускаются
+func (m *Migrator) Plan(ctx context.Context) (Plan, error) {
+
+	plan := Plan{Files: make(map[string]string)}
+	for _, spec := range m.opts.Apps {
+		if spec.Key == "" {
+			return plan, fmt.Errorf("%w: %s.%s", ErrNoKey, spec.Namespace, spec.Code)
+		}
+
+		targets, err := m.targetKeys(ctx, spec)
+		if err != nil {
+			return plan, err
+		}
+
+		ap := AppPlan{Namespace: spec.Namespace, Code: spec.Code, Key: spec.Key, Items: make([]ItemPlan, 0)}
+		seen := make(map[string]struct{})
+		err = m.app(m.source, spec).Search().Where(spec.Filter).Select(spec.Key).Size(chunkSize).Iter().
+			Each(ctx, func(items []e365_gateway.DynamicItem) error {
+				for _, d := range items {
+					key := keyOf(d, spec.Key)
+					it := ItemPlan{Key: key, Source: d.Common().ID, Action: ActionCreate}
+					_, duplicate := seen[key]
+					switch {
+					case key == "":
+						it.Action, it.Error = ActionSkip, "key is empty"
+					case duplicate:
+						it.Action, it.Error = ActionSkip, e365_gateway.ErrDuplicateKey.Error()
+					case len(targets[key]) > 1:
+						it.Action, it.Error = ActionSkip, e365_gateway.ErrAmbiguousKey.Error()
+					case len(targets[key]) == 1:
+						it.Action, it.Target = ActionUpdate, targets[key][0]
+					}
+					seen[key] = struct{}{}
+					ap.Items = append(ap.Items, it)
+				}
+				return nil
+			})
+		if err != nil {
+			return plan, err
+		}
+		plan.Apps = append(plan.Apps, ap)
+	}
+
+	return plan, nil
+}
+
+// targetKeys возвращает id элементов целевого стенда по значениям ключевого поля
+func (m *Migrator) targetKeys(ctx context.Context, spec AppSpec) (map[string][]string, error) {
+	targets := make(map[string][]string)
+	err := m.app(m.target, spec).Search().Select(spec.Key).Size(chunkSize).Iter().
+		Each(ctx, func(items []e365_gateway.DynamicItem) error {
+			for _, d := range items {
+				if key := keyOf(d, spec.Key); key != "" {
+					targets[key] = append(targets[key], d.Common().ID)
+				}
+			}
+			return nil
+		})
+	return targets, err
+}
+
+func keyOf(d e365_gateway.DynamicItem, key string) string {
+	v, ok := d.Get(key)
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// state - соответствие id элементов и файлов стендов во время Apply
+type state struct {
+	mu    sync.Mutex
+	group singleflight.Group
+	ids   map[string]map[string]string
+	files map[string]string
+	apps  map[string]*appState
+}
+
+// appState - описание приложения на обоих стендах
+type appState struct {
+	name         string
+	source       e365_gateway.App[e365_gateway.DynamicItem]
+	target       e365_gateway.App[e365_gateway.DynamicItem]
+	schema       e365_gateway.AppSchema
+	fields       []string
+	sourceStatus map[int]string
+	targetStatus map[int]string
+}
+
+func (st *state) setID(app, source, target string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ids[app][source] = target
+}
+
+// targets возвращает id элементов целевого стенда. ok = false, если какой-то элемент еще не перенесен
+func (st *state) targets(app string, sources []string) ([]string, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	targets := make([]string, 0, len(sources))
+	for _, id := range sources {
+		target, ok := st.ids[app][id]
+		if !ok {
+			return nil, false
+		}
+		targets = append(targets, target)
+	}
+	return targets, true
+}
+
+func (m *Migrator) newState(ctx context.Context, plan *Plan) (*state, error) {
+
+	if plan.Files == nil {
+		plan.Files = make(map[string]string)
+	}
+	st := &state{
+		ids:   make(map[string]map[string]string),
+		files: plan.Files,
+		apps:  make(map[string]*appState),
+	}
+	for i := range plan.Apps {
+		ap := &plan.Apps[i]
+		spec := m.spec(*ap)
+		as := &appState{
+			name:   ap.name(),
+			source: m.app(m.source, spec),
+			target: m.app(m.target, spec),
+		}
+
+		var err error
+		if as.schema, err = as.source.Schema(ctx); err != nil {
+			return nil, err
+		}
+		as.fields = spec.Fields
+		if len(as.fields) == 0 {
+			as.fields = []string{"__name"}
+			for _, f := range as.schema.Fields {
+				if !strings.HasPrefix(f.Code, "__") && f.Type != e365_gateway.FieldTypeStatus {
+					as.fields = append(as.fields, f.Code)
+				}
+			}
+		}
+		if !contains(as.fields, ap.Key) {
+			as.fields = append(as.fields, ap.Key)
+		}
+		if as.sourceStatus, err = statusCodes(ctx, as.source); err != nil {
+			return nil, err
+		}
+		if as.targetStatus, err = statusCodes(ctx, as.target); err != nil {
+			return nil, err
+		}
+
+		if err = m.resume(ctx, spec, ap); err != nil {
+			return nil, err
+		}
+
+		st.apps[as.name] = as
+		st.ids[as.name] = make(map[string]string)
+		for _, it := range ap.Items {
+			if it.Target != "" {
+				st.ids[as.name][it.Source] = it.Target
+			}
+		}
+	}
+	return st, nil
+}
+
+// resume находит на целевом стенде элементы, которые были созданы, но не отмечены в плане
+// (например, если перенос прервался до сохранения плана), чтобы они не создавались повторно
+func (m *Migrator) resume(ctx context.Context, spec AppSpec, ap *AppPlan) error {
+	pending := false
+	for _, it := range ap.Items {
+		if it.Action == ActionCreate && !it.Done && it.Target == "" {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return nil
+	}
+
+	spec.Key = ap.Key
+	targets, err := m.targetKeys(ctx, spec)
+	if err != nil {
+		return err
+	}
+	for i := range ap.Items {
+		it := &ap.Items[i]
+		if it.Action != ActionCreate || it.Done || it.Target != "" {
+			continue
+		}
+		switch len(targets[it.Key]) {
+		case 0:
+		case 1:
+			it.Target = targets[it.Key][0]
+		default:
+			it.Action, it.Error = ActionSkip, e365_gateway.ErrAmbiguousKey.Error()
+		}
+	}
+	return nil
+}
+
+func statusCodes(ctx context.Context, app e365_gateway.App[e365_gateway.DynamicItem]) (map[int]string, error) {
+	info, err := app.GetStatusInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	codes := make(map[int]string, len(info.StatusItems))
+	for _, s := range info.StatusItems {
+		codes[s.Id] = s.Code
+	}
+	return codes, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply выполняет план: создает и обновляет элементы на целевом стенде пачками по 100 (не более чем в
+// Concurrency запросов одновременно) и после каждой пачки передает план в save (например, SavePlan).
+// Уже выполненные элементы пропускаются. Ошибки отдельных элементов записываются в план (ItemPlan.Error),
+// такие элементы будут перенесены повторно при следующем вызове Apply.
+// Ошибка возвращается, только если не удалось получить описание приложений, элементы исходного стенда или сохранить план
+func (m *Migrator) Apply(ctx context.Context, plan *Plan, save func(p Plan) error) (Report, error) {
+
+	st, err := m.newState(ctx, plan)
+	if err != nil {
+		return Report{}, err
+	}
+
+	step := func(deferred bool) error {
+		for i := range plan.Apps {
+			ap := &plan.Apps[i]
+			pending := make([]int, 0)
+			for j, it := range ap.Items {
+				switch {
+				case it.Action == ActionSkip:
+				case !deferred && !it.Done, deferred && it.Done && len(it.Deferred) > 0:
+					pending = append(pending, j)
+				}
+			}
+
+			for start := 0; start < len(pending); start += chunkSize {
+				end := start + chunkSize
+				if end > len(pending) {
+					end = len(pending)
+				}
+				if err := m.applyChunk(ctx, st, st.apps[ap.name()], ap, pending[start:end], deferred); err != nil {
+					return err
+				}
+				if save != nil {
+					if err := save(*plan); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	if err = step(false); err != nil {
+		return plan.Report(), err
+	}
+	// ссылки на элементы, перенесенные позже ссылающихся на них
+	if err = step(true); err != nil {
+		return plan.Report(), err
+	}
+	return plan.Report(), nil
+}
+
+// applyChunk переносит элементы плана с индексами idx
+func (m *Migrator) applyChunk(ctx context.Context, st *state, as *appState, ap *AppPlan, idx []int, deferred bool) error {
+
+	ids := make([]string, 0, len(idx))
+	for _, i := range idx {
+		ids = append(ids, ap.Items[i].Source)
+	}
+	items, _, err := as.source.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]e365_gateway.DynamicItem, len(items))
+	for _, item := range items {
+		found[item.Common().ID] = item
+	}
+
+	eg := errgroup.Group{}
+	eg.SetLimit(m.opts.Concurrency)
+	for _, i := range idx {
+		it := &ap.Items[i]
+		item, ok := found[it.Source]
+		if !ok {
+			it.Error = e365_gateway.ErrItemNotFound.Error()
+			continue
+		}
+		eg.Go(func() error {
+			it.Error = ""
+			if err := m.applyItem(ctx, st, as, it, item, deferred); err != nil {
+				it.Error = err.Error()
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return ctx.Err()
+}
+
+// applyItem создает или обновляет элемент на целевом стенде. Если deferred, заполняет только отложенные ссылки
+func (m *Migrator) applyItem(ctx context.Context, st *state, as *appState, it *ItemPlan, item e365_gateway.DynamicItem, deferred bool) error {
+
+	fields := as.fields
+	if deferred {
+		fields = it.Deferred
+	}
+	body, unresolved, err := m.body(ctx, st, as, item, fields)
+	if err != nil {
+		return err
+	}
+
+	if deferred {
+		if len(unresolved) > 0 {
+			return fmt.Errorf("linked items are not migrated: %s", strings.Join(unresolved, ", "))
+		}
+		if _, err = as.target.Update(ctx, it.Target, body); err != nil {
+			return err
+		}
+		it.Deferred = nil
+		return nil
+	}
+
+	var saved e365_gateway.DynamicItem
+	if it.Target == "" {
+		if saved, err = as.target.Create(ctx, body); err != nil {
+			return err
+		}
+		it.Target = saved.Common().ID
+		st.setID(as.name, it.Source, it.Target)
+	} else if saved, err = as.target.Update(ctx, it.Target, body); err != nil {
+		return err
+	}
+
+	// статус переносится по коду, т.к. id статусов на стендах могут отличаться
+	code := as.sourceStatus[item.Common().Status.Status]
+	if code != "" && as.targetStatus[saved.Common().Status.Status] != code {
+		if _, err = as.target.SetStatus(ctx, it.Target, code); err != nil {
+			return err
+		}
+	}
+
+	it.Deferred = unresolved
+	it.Done = true
+	return nil
+}
+
+// body собирает значения полей fields для целевого стенда и возвращает поля со ссылками на еще не перенесенные элементы
+func (m *Migrator) body(ctx context.Context, st *state, as *appState, item e365_gateway.DynamicItem, fields []string) (e365_gateway.DynamicItem, []string, error) {
+
+	var body e365_gateway.DynamicItem
+	var unresolved []string
+	for _, code := range fields {
+		raw, ok := item.Raw(code)
+		if !ok {
+			continue
+		}
+		if string(bytes.TrimSpace(raw)) == "null" {
+			if err := body.Set(code, raw); err != nil {
+				return body, nil, err
+			}
+			continue
+		}
+
+		var value interface{} = raw
+		fs, _ := as.schema.Field(code)
+		switch {
+		case fs.Type == e365_gateway.FieldTypeCollection && fs.LinkedApp != nil:
+			linked := fs.LinkedApp.Namespace + "." + fs.LinkedApp.Code
+			if _, ok := st.apps[linked]; !ok {
+				// id исходного стенда на целевом стенде не существуют
+				continue
+			}
+			sources, _ := item.Refs(code)
+			targets, ok := st.targets(linked, sources)
+			if !ok {
+				unresolved = append(unresolved, code)
+				continue
+			}
+			value = targets
+		case fs.Type == e365_gateway.FieldTypeFile:
+			if m.opts.Directory == "" {
+				continue
+			}
+			var err error
+			if value, err = m.uploadFiles(ctx, st, raw); err != nil {
+				return body, nil, fmt.Errorf("%s: %w", code, err)
+			}
+		}
+		if err := body.Set(code, value); err != nil {
+			return body, nil, err
+		}
+	}
+	return body, unresolved, nil
+}
+
+// uploadFiles загружает файлы поля (id или массив id) на целевой стенд и возвращает значение с новыми id
+func (m *Migrator) uploadFiles(ctx context.Context, st *state, raw json.RawMessage) (interface{}, error) {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return m.upload(ctx, st, id)
+	}
+	ids := make([]string, 0)
+	if err := json.Unmarshal(raw, &ids); err != nil {
+		return nil, err
+	}
+	targets := make([]string, 0, len(ids))
+	for _, id := range ids {
+		target, err := m.upload(ctx, st, id)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// upload скачивает файл с исходного стенда и загружает его в папку целевого стенда (каждый файл загружается один раз,
+// в том числе если на него одновременно ссылаются несколько элементов).
+// FileAdapter не возвращает исходное имя файла, поэтому на целевом стенде файл называется по id исходного
+func (m *Migrator) upload(ctx context.Context, st *state, id string) (string, error) {
+	target, err, _ := st.group.Do(id, func() (interface{}, error) {
+		st.mu.Lock()
+		target, ok := st.files[id]
+		st.mu.Unlock()
+		if ok {
+			return target, nil
+		}
+
+		rc, err := m.files.DownloadFile(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = rc.Close()
+		}()
+		buf := new(bytes.Buffer)
+		if _, err = io.Copy(buf, rc); err != nil {
+			return "", err
+		}
+		f, err := m.dir.Upload(ctx, buf, id)
+		if err != nil {
+			return "", err
+		}
+
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.files[id] = f.ID
+		return f.ID, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return target.(string), nil
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"github.com/inse91/elma_lib/internal/elmatest"
+	"github.com/stretchr/testify/require"
+)
+
+// newStand запускает имитацию стенда с приложениями ref.goods и ref.categories и статусами new и done
+func newStand(t *testing.T, prefix string, statusNew, statusDone int) *elmatest.Stand {
+	st := elmatest.NewStand(t, prefix)
+	for _, code := range []string{"goods", "categories"} {
+		app := st.App("ref", code)
+		app.StatusInfo = map[string]interface{}{"statusItems": []map[string]interface{}{
+			{"id": statusNew, "code": "new"}, {"id": statusDone, "code": "done"},
+		}}
+		app.Fields = []map[string]interface{}{
+			{"code": "external_id", "type": "STRING"},
+			{"code": "price", "type": "FLOAT"},
+			{"code": "category", "type": "SYS_COLLECTION", "data": map[string]interface{}{"namespace": "ref", "code": "categories"}},
+			{"code": "related", "type": "SYS_COLLECTION", "data": map[string]interface{}{"namespace": "ref", "code": "goods"}},
+			{"code": "supplier", "type": "SYS_COLLECTION", "data": map[string]interface{}{"namespace": "ref", "code": "suppliers"}},
+			{"code": "photo", "type": "FILE"},
+		}
+	}
+	return st
+}
+
+func stand(st *elmatest.Stand) e365_gateway.Stand {
+	return e365_gateway.NewStand(e365_gateway.StandConfig{Host: st.URL})
+}
+
+func TestMigrate(t *testing.T) {
+
+	ctxBg := context.Background()
+	source := newStand(t, "00000000-0000-4000-8000-", 1, 2)
+	sourceGoods, sourceCategories := source.App("ref", "goods"), source.App("ref", "categories")
+	target := newStand(t, "11111111-0000-4000-8000-", 5, 6)
+	targetGoods, targetCategories := target.App("ref", "goods"), target.App("ref", "categories")
+
+	catA := source.Add(sourceCategories, map[string]interface{}{"__name": "A", "external_id": "cat-a"})
+	catB := source.Add(sourceCategories, map[string]interface{}{"__name": "B", "external_id": "cat-b"})
+	source.Files["00000000-0000-4000-8000-f00000000100"] = "jpeg"
+
+	g1 := source.Add(sourceGoods, map[string]interface{}{
+		"__name": "g1", "external_id": "g-1", "price": 10, "category": []string{catA},
+		"photo": "00000000-0000-4000-8000-f00000000100", "__status": map[string]interface{}{"status": 2},
+		"supplier": []string{"33333333-0000-4000-8000-000000000000"},
+	})
+	g2 := source.Add(sourceGoods, map[string]interface{}{"__name": "g2", "external_id": "g-2", "price": 20, "category": []string{catB}, "related": []string{g1}})
+	source.Add(sourceGoods, map[string]interface{}{"__name": "g3", "external_id": "g-2"})
+	source.Add(sourceGoods, map[string]interface{}{"__name": "g4", "external_id": "g-4"})
+
+	// cat-a уже есть на целевом стенде, g-1 ссылается на g-2, который переносится позже
+	targetCatA := target.Add(targetCategories, map[string]interface{}{"__name": "old", "external_id": "cat-a"})
+	sourceGoods.Items[0]["related"] = []string{g2}
+	failed := map[string]bool{"g-4": true}
+	targetGoods.Fail = func(ctx map[string]interface{}) bool {
+		key, _ := ctx["external_id"].(string)
+		return failed[key]
+	}
+
+	m := New(stand(source), stand(target), Options{
+		Apps: []AppSpec{
+			{Namespace: "ref", Code: "goods", Key: "external_id"},
+			{Namespace: "ref", Code: "categories", Key: "external_id"},
+		},
+		Directory: "22222222-0000-4000-8000-000000000000",
+	})
+
+	plan, err := m.Plan(ctxBg)
+	require.NoError(t, err)
+	require.Len(t, plan.Apps, 2)
+	require.Equal(t, []Action{ActionCreate, ActionCreate, ActionSkip, ActionCreate}, []Action{
+		plan.Apps[0].Items[0].Action, plan.Apps[0].Items[1].Action, plan.Apps[0].Items[2].Action, plan.Apps[0].Items[3].Action,
+	})
+	require.Equal(t, ItemPlan{Key: "cat-a", Source: catA, Target: targetCatA, Action: ActionUpdate}, plan.Apps[1].Items[0])
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	report, err := m.Apply(ctxBg, &plan, func(p Plan) error {
+		return SavePlan(path, p)
+	})
+	require.NoError(t, err)
+	require.Equal(t, AppReport{App: "ref.goods", Created: 2, Skipped: 1, Pending: 1, Errors: []string{
+		"g-2 (" + sourceGoods.Items[2]["__id"].(string) + "): " + e365_gateway.ErrDuplicateKey.Error(),
+		"g-4 (" + sourceGoods.Items[3]["__id"].(string) + "): response status is not ok: 500 Internal Server Error: failed",
+	}}, report.Apps[0])
+	require.Equal(t, AppReport{App: "ref.categories", Created: 1, Updated: 1}, report.Apps[1])
+	require.Equal(t, 1, report.Files)
+
+	goods := make(map[string]map[string]interface{})
+	for _, item := range targetGoods.Items {
+		goods[item["__name"].(string)] = item
+	}
+	require.Len(t, goods, 2)
+	categories := targetCategories.Items
+	require.Equal(t, "A", categories[0]["__name"])
+	require.Equal(t, []interface{}{targetCatA}, goods["g1"]["category"])
+	require.Equal(t, []interface{}{categories[1]["__id"]}, goods["g2"]["category"])
+	require.Equal(t, []interface{}{goods["g2"]["__id"]}, goods["g1"]["related"])
+	require.Equal(t, []interface{}{goods["g1"]["__id"]}, goods["g2"]["related"])
+	require.Equal(t, map[string]interface{}{"order": float64(0), "status": float64(6)}, goods["g1"]["__status"])
+	// ref.suppliers не переносится: id исходного стенда не копируются
+	require.NotContains(t, goods["g1"], "supplier")
+	photo := goods["g1"]["photo"].(string)
+	require.Equal(t, "00000000-0000-4000-8000-f00000000100:jpeg", target.Files[photo])
+
+	// перенос продолжается по сохраненному плану: повторяются только неудачные элементы
+	saved, err := LoadPlan(path)
+	require.NoError(t, err)
+	require.Equal(t, plan, saved)
+	delete(failed, "g-4")
+	writes := targetGoods.Writes
+	report, err = m.Apply(ctxBg, &saved, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Apps[0].Created)
+	require.Zero(t, report.Apps[0].Pending)
+	require.Equal(t, writes+1, targetGoods.Writes)
+	require.Len(t, targetGoods.Items, 3)
+
+	_, err = New(stand(source), stand(target), Options{Apps: []AppSpec{{Namespace: "ref", Code: "goods"}}}).Plan(ctxBg)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	require.NoError(t, saved.Check([]AppSpec{
+		{Namespace: "ref", Code: "categories", Key: "external_id"},
+		{Namespace: "ref", Code: "goods", Key: "external_id"},
+	}))
+	require.ErrorIs(t, saved.Check([]AppSpec{{Namespace: "ref", Code: "goods", Key: "external_id"}}), ErrPlanMismatch)
+	require.ErrorIs(t, saved.Check([]AppSpec{
+		{Namespace: "ref", Code: "categories", Key: "external_id"},
+		{Namespace: "ref", Code: "goods", Key: "__name"},
+	}), ErrPlanMismatch)
+}
+
+func TestMigrateResume(t *testing.T) {
+
+	ctxBg := context.Background()
+	source := newStand(t, "00000000-0000-4000-8000-", 1, 2)
+	sourceGoods := source.App("ref", "goods")
+	target := newStand(t, "11111111-0000-4000-8000-", 5, 6)
+	targetGoods := target.App("ref", "goods")
+
+	photo := "00000000-0000-4000-8000-f00000000100"
+	source.Files[photo] = "jpeg"
+	for _, key := range []string{"g-1", "g-2", "g-3"} {
+		source.Add(sourceGoods, map[string]interface{}{"__name": key, "external_id": key, "photo": photo})
+	}
+
+	m := New(stand(source), stand(target), Options{
+		Apps:      []AppSpec{{Namespace: "ref", Code: "goods", Key: "external_id"}},
+		Directory: "22222222-0000-4000-8000-000000000000",
+	})
+	plan, err := m.Plan(ctxBg)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, SavePlan(path, plan))
+
+	// файл, на который ссылаются все элементы, загружается один раз
+	report, err := m.Apply(ctxBg, &plan, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Apps[0].Created)
+	require.Len(t, target.Files, 1)
+
+	// перенос прервался до сохранения плана: созданные элементы находятся по ключу и не создаются повторно
+	saved, err := LoadPlan(path)
+	require.NoError(t, err)
+	report, err = m.Apply(ctxBg, &saved, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Apps[0].Created)
+	require.Len(t, targetGoods.Items, 3)
+	for i, it := range saved.Apps[0].Items {
+		require.Equal(t, plan.Apps[0].Items[i].Target, it.Target)
+	}
+}
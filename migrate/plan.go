@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrPlanMismatch = errors.New("plan does not match apps")
+
+// Action - действие с элементом на целевом стенде
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	// ActionSkip - элемент не переносится (см. ItemPlan.Error)
+	ActionSkip Action = "skip"
+)
+
+// Plan - план переноса. Apply отмечает в нем выполненные элементы, поэтому сохраненный план
+// (SavePlan) позволяет продолжить прерванный перенос
+type Plan struct {
+	Apps []AppPlan `json:"apps"`
+	// Files - id файлов исходного стенда -> id файлов, загруженных на целевой стенд
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// AppPlan - план переноса элементов приложения
+type AppPlan struct {
+	Namespace string     `json:"namespace"`
+	Code      string     `json:"code"`
+	Key       string     `json:"key"`
+	Items     []ItemPlan `json:"items"`
+}
+
+// ItemPlan - план переноса элемента
+type ItemPlan struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	// Target - id элемента на целевом стенде (для ActionCreate заполняется после создания)
+	Target string `json:"target,omitempty"`
+	Action Action `json:"action"`
+	Done   bool   `json:"done,omitempty"`
+	// Deferred - поля со ссылками на элементы, которые еще не были перенесены. Они заполняются в конце Apply
+	Deferred []string `json:"deferred,omitempty"`
+	// Error - причина пропуска элемента или ошибка последней попытки переноса
+	Error string `json:"error,omitempty"`
+}
+
+func (ap AppPlan) name() string {
+	return ap.Namespace + "." + ap.Code
+}
+
+// Report - итог переноса
+type Report struct {
+	Apps []AppReport
+	// Files - кол-во файлов, загруженных на целевой стенд
+	Files int
+}
+
+// AppReport - итог переноса элементов приложения
+type AppReport struct {
+	App     string
+	Created int
+	Updated int
+	Skipped int
+	// Pending - элементы, которые еще не перенесены (в том числе из-за ошибок) или ждут заполнения ссылок
+	Pending int
+	Errors  []string
+}
+
+// Report подсчитывает итог переноса по плану
+func (p Plan) Report() Report {
+	r := Report{Files: len(p.Files)}
+	for _, ap := range p.Apps {
+		ar := AppReport{App: ap.name()}
+		for _, it := range ap.Items {
+			switch {
+			case it.Action == ActionSkip:
+				ar.Skipped++
+			case !it.Done || len(it.Deferred) > 0:
+				ar.Pending++
+			case it.Action == ActionCreate:
+				ar.Created++
+			default:
+				ar.Updated++
+			}
+			if it.Error != "" {
+				ar.Errors = append(ar.Errors, fmt.Sprintf("%s (%s): %s", it.Key, it.Source, it.Error))
+			}
+		}
+		r.Apps = append(r.Apps, ar)
+	}
+	return r
+}
+
+// Check проверяет, что план создан для тех же приложений с теми же ключевыми полями, что и apps
+// (например, перед продолжением переноса по плану из файла)
+func (p Plan) Check(apps []AppSpec) error {
+	planned := make([]string, 0, len(p.Apps))
+	for _, ap := range p.Apps {
+		planned = append(planned, ap.name()+":"+ap.Key)
+	}
+	expected := make([]string, 0, len(apps))
+	for _, spec := range apps {
+		expected = append(expected, spec.Namespace+"."+spec.Code+":"+spec.Key)
+	}
+	sort.Strings(planned)
+	sort.Strings(expected)
+	if strings.Join(planned, ",") != strings.Join(expected, ",") {
+		return fmt.Errorf("%w: plan has %s, expected %s", ErrPlanMismatch, strings.Join(planned, ", "), strings.Join(expected, ", "))
+	}
+	return nil
+}
+
+// LoadPlan читает план из файла
+func LoadPlan(path string) (Plan, error) {
+	var p Plan
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err = json.Unmarshal(bts, &p); err != nil {
+		return p, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// SavePlan записывает план в файл через временный файл и переименование,
+// чтобы при сбое во время записи остался предыдущий план
+func SavePlan(path string, p Plan) error {
+	bts, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(bts); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,6 +1,9 @@
 package e365_gateway
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type AppCommon struct {
 	ID                  string    `json:"__id,omitempty"`
@@ -23,3 +26,23 @@ type Status struct {
 	Order  int `json:"order,omitempty"`
 	Status int `json:"status,omitempty"`
 }
+
+// appCommon позволяет получить служебные поля из любой структуры, в которую встроен AppCommon
+func (c AppCommon) appCommon() AppCommon {
+	return c
+}
+
+// commonOf извлекает служебные поля элемента приложения.
+// Если в элемент не встроен AppCommon, поля извлекаются через json
+func commonOf(item interface{}) AppCommon {
+	if c, ok := item.(interface{ appCommon() AppCommon }); ok {
+		return c.appCommon()
+	}
+	var c AppCommon
+	bts, err := json.Marshal(item)
+	if err != nil {
+		return c
+	}
+	_ = json.Unmarshal(bts, &c)
+	return c
+}
@@ -8,6 +8,8 @@ type Settings struct {
 	Stand     Stand
 	Namespace string
 	Code      string
+	// Cache - кэш элементов для GetByID и GetByIDs (необязательный). Create, Update и SetStatus обновляют его
+	Cache Cache
 }
 
 func (s Settings) toAppUrl() string {
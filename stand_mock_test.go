@@ -0,0 +1,36 @@
+package e365_gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/inse91/elma_lib/internal/elmatest"
+)
+
+// fakeApp - приложение ns.app на имитации стенда (см. internal/elmatest)
+type fakeApp struct {
+	*elmatest.Stand
+	*elmatest.App
+}
+
+func newFakeApp(t *testing.T, items []map[string]interface{}) (*fakeApp, Settings) {
+	st := elmatest.NewStand(t, "")
+	fa := &fakeApp{Stand: st, App: st.App("ns", "app")}
+	fa.Items = items
+	return fa, Settings{
+		Stand:     NewStand(StandConfig{Host: st.URL}),
+		Namespace: "ns",
+		Code:      "app",
+	}
+}
+
+// fakeItem создает элемент для fakeApp с порядковым uuid
+func fakeItem(n int, createdAt time.Time, price float64) map[string]interface{} {
+	item := elmatest.Item(fakeID(n), createdAt)
+	item["price"] = price
+	return item
+}
+
+func fakeID(n int) string {
+	return elmatest.ID(n)
+}
@@ -0,0 +1,237 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	gosync "sync"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+const (
+	fileRecords     = "records.jsonl"
+	fileCheckpoints = "checkpoints.json"
+)
+
+// FileStore - хранилище в каталоге на диске. Записи держатся в памяти, а изменения дописываются в records.jsonl
+// (по строке на операцию) и при открытии применяются заново. Позиции хранятся в checkpoints.json,
+// который заменяется целиком через переименование, поэтому после сбоя остается предыдущая позиция.
+// Compact переписывает журнал, оставляя по одной строке на запись
+type FileStore struct {
+	*MemoryStore
+	dir  string
+	mu   gosync.Mutex
+	file *os.File
+}
+
+// fileOp - строка журнала records.jsonl
+type fileOp struct {
+	Put    *Record `json:"put,omitempty"`
+	Delete string  `json:"delete,omitempty"`
+}
+
+// OpenFileStore открывает (или создает) хранилище в каталоге dir
+func OpenFileStore(dir string) (*FileStore, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	fs := &FileStore{MemoryStore: NewMemoryStore(), dir: dir}
+
+	size, err := fs.replay()
+	if err != nil {
+		return nil, err
+	}
+	bts, err := os.ReadFile(filepath.Join(dir, fileCheckpoints))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err = json.Unmarshal(bts, &fs.checkpoints); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", fileCheckpoints, err)
+		}
+	}
+
+	fs.file, err = os.OpenFile(filepath.Join(dir, fileRecords), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// отрезаем недописанную строку, чтобы следующие операции не склеились с ней
+	if err = fs.file.Truncate(size); err != nil {
+		_ = fs.file.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+// replay применяет журнал к записям в памяти и возвращает размер его целой части.
+// Недописанная последняя строка (после сбоя) пропускается
+func (fs *FileStore) replay() (int64, error) {
+
+	f, err := os.Open(filepath.Join(fs.dir, fileRecords))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var size int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(line))
+		var op fileOp
+		if err = json.Unmarshal(line, &op); err != nil {
+			return 0, fmt.Errorf("decode %s: %w", fileRecords, err)
+		}
+		if op.Put != nil {
+			fs.records[op.Put.ID] = *op.Put
+		}
+		if op.Delete != "" {
+			delete(fs.records, op.Delete)
+		}
+	}
+}
+
+// write дописывает операции в журнал и сбрасывает его на диск (вызывается под fs.mu)
+func (fs *FileStore) write(ops []fileOp) error {
+	w := bufio.NewWriter(fs.file)
+	enc := json.NewEncoder(w)
+	for _, op := range ops {
+		if err := enc.Encode(op); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+func (fs *FileStore) Put(ctx context.Context, records ...Record) error {
+	ops := make([]fileOp, 0, len(records))
+	for i := range records {
+		ops = append(ops, fileOp{Put: &records[i]})
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.write(ops); err != nil {
+		return err
+	}
+	return fs.MemoryStore.Put(ctx, records...)
+}
+
+func (fs *FileStore) Delete(ctx context.Context, ids ...string) error {
+	ops := make([]fileOp, 0, len(ids))
+	for _, id := range ids {
+		ops = append(ops, fileOp{Delete: id})
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.write(ops); err != nil {
+		return err
+	}
+	return fs.MemoryStore.Delete(ctx, ids...)
+}
+
+func (fs *FileStore) Save(ctx context.Context, key string, cp e365_gateway.Checkpoint) error {
+	if err := fs.MemoryStore.Save(ctx, key, cp); err != nil {
+		return err
+	}
+
+	fs.MemoryStore.mu.RLock()
+	bts, err := json.Marshal(fs.checkpoints)
+	fs.MemoryStore.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return writeFileAtomic(filepath.Join(fs.dir, fileCheckpoints), bts)
+}
+
+// Compact переписывает журнал так, чтобы в нем осталось по одной строке на запись
+func (fs *FileStore) Compact(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path := filepath.Join(fs.dir, fileRecords)
+	tmp, err := os.CreateTemp(fs.dir, fileRecords+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	err = fs.MemoryStore.Each(ctx, func(r Record) error {
+		return enc.Encode(fileOp{Put: &r})
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	_ = fs.file.Close()
+	fs.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// Close закрывает журнал
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.file.Close()
+}
+
+// writeFileAtomic записывает файл через временный файл и переименование
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	gosync "sync"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+// Record - элемент приложения в локальном хранилище
+type Record struct {
+	ID        string          `json:"id"`
+	Version   int             `json:"version"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Deleted   bool            `json:"deleted,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Store - локальное хранилище элементов приложения и позиций синхронизации.
+// Реализации для SQL достаточно таблицы записей (id - первичный ключ) и таблицы позиций (key - первичный ключ).
+// Методы вызываются из одной горутины Syncer, но чтение (Get, Each) может выполняться параллельно с синхронизацией
+type Store interface {
+	// Put добавляет или заменяет записи
+	Put(ctx context.Context, records ...Record) error
+	// Delete удаляет записи по id (отсутствующие id пропускаются)
+	Delete(ctx context.Context, ids ...string) error
+	// Get возвращает запись по id
+	Get(ctx context.Context, id string) (Record, bool, error)
+	// Each передает в fn все записи. Ошибка из fn прерывает обход
+	Each(ctx context.Context, fn func(r Record) error) error
+	// Load и Save хранят позицию синхронизации (см. e365_gateway.Checkpoint)
+	e365_gateway.CheckpointStore
+}
+
+// MemoryStore - хранилище в памяти процесса
+type MemoryStore struct {
+	mu          gosync.RWMutex
+	records     map[string]Record
+	checkpoints map[string]e365_gateway.Checkpoint
+}
+
+// NewMemoryStore создает хранилище в памяти процесса
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:     make(map[string]Record),
+		checkpoints: make(map[string]e365_gateway.Checkpoint),
+	}
+}
+
+func (ms *MemoryStore) Put(_ context.Context, records ...Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, r := range records {
+		ms.records[r.ID] = r
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Delete(_ context.Context, ids ...string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, id := range ids {
+		delete(ms.records, id)
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Get(_ context.Context, id string) (Record, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	r, ok := ms.records[id]
+	return r, ok, nil
+}
+
+// Each передает в fn все записи в порядке id
+func (ms *MemoryStore) Each(ctx context.Context, fn func(r Record) error) error {
+	ms.mu.RLock()
+	records := make([]Record, 0, len(ms.records))
+	for _, r := range ms.records {
+		records = append(records, r)
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len возвращает кол-во записей
+func (ms *MemoryStore) Len() int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return len(ms.records)
+}
+
+func (ms *MemoryStore) Load(_ context.Context, key string) (e365_gateway.Checkpoint, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	cp, ok := ms.checkpoints[key]
+	return cp, ok, nil
+}
+
+func (ms *MemoryStore) Save(_ context.Context, key string, cp e365_gateway.Checkpoint) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.checkpoints[key] = cp
+	return nil
+}
+
+// Get возвращает элемент из хранилища, декодированный в T
+func Get[T interface{}](ctx context.Context, store Store, id string) (T, bool, error) {
+	var t T
+	r, ok, err := store.Get(ctx, id)
+	if err != nil || !ok {
+		return t, false, err
+	}
+	if err = json.Unmarshal(r.Data, &t); err != nil {
+		return t, false, err
+	}
+	return t, true, nil
+}
+
+// Each передает в fn все элементы хранилища, декодированные в T (удаленные элементы пропускаются)
+func Each[T interface{}](ctx context.Context, store Store, fn func(item T) error) error {
+	return store.Each(ctx, func(r Record) error {
+		if r.Deleted {
+			return nil
+		}
+		var t T
+		if err := json.Unmarshal(r.Data, &t); err != nil {
+			return err
+		}
+		return fn(t)
+	})
+}
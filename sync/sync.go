@@ -0,0 +1,268 @@
+// Package sync зеркалирует элементы приложения elma365 в локальное хранилище (Store),
+// чтобы сервисы читали данные локально, а не через /list.
+//
+// Сначала выполняется полная загрузка (Search().ScanEach), затем хранилище обновляется по изменениям
+// (App.PollBatch по __updatedAt и __deletedAt). Если задан фильтр, изменения запрашиваются без него, а измененные
+// элементы проверяются одним запросом на пачку из 100: элементы, которые перестали подходить под фильтр, удаляются
+// из хранилища. Позиция синхронизации хранится в том же Store и сохраняется только после записи изменений,
+// поэтому после сбоя синхронизация продолжается с последней сохраненной позиции.
+// Если сбой произошел во время полной загрузки, она выполняется заново.
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+)
+
+const (
+	defaultKey      = "sync"
+	defaultInterval = 30 * time.Second
+	// loadOverlap - насколько раньше последнего загруженного изменения начинаются изменения после полной загрузки
+	// (изменения, сохраненные во время загрузки с задержкой, могут получить более раннее время)
+	loadOverlap = 30 * time.Second
+)
+
+// Options - параметры синхронизации
+type Options struct {
+	// Filter - фильтр элементов, которые попадают в хранилище.
+	// Измененные элементы проверяются на соответствие фильтру одним запросом на каждые 100 изменений
+	Filter e365_gateway.SearchFilter
+	// Interval - пауза между запросами изменений в Run (по умолчанию 30 секунд)
+	Interval time.Duration
+	// Key - ключ позиции синхронизации в Store (по умолчанию "sync").
+	// Нужен, если в одном Store хранятся данные нескольких синхронизаций
+	Key string
+	// KeepDeleted - не удалять записи удаленных элементов, а помечать их Record.Deleted
+	KeepDeleted bool
+	// OnError вызывается при ошибке очередного шага в Run. Если не задан, Run завершается с ошибкой
+	OnError func(err error)
+}
+
+// Stats - кол-во изменений, примененных за шаг синхронизации
+type Stats struct {
+	Loaded  int
+	Created int
+	Updated int
+	Deleted int
+}
+
+// Syncer - синхронизация приложения с контекстом T и локального хранилища
+type Syncer[T interface{}] struct {
+	app   e365_gateway.App[T]
+	store Store
+	opts  Options
+}
+
+// New создает синхронизацию приложения app с хранилищем store
+func New[T interface{}](app e365_gateway.App[T], store Store, opts Options) *Syncer[T] {
+	if opts.Key == "" {
+		opts.Key = defaultKey
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	return &Syncer[T]{app: app, store: store, opts: opts}
+}
+
+// Run выполняет полную загрузку (если она еще не выполнялась) и затем каждые Interval применяет изменения.
+// Завершается при отмене ctx
+func (s *Syncer[T]) Run(ctx context.Context) error {
+	for {
+		if _, err := s.Step(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if s.opts.OnError == nil {
+				return err
+			}
+			s.opts.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.opts.Interval):
+		}
+	}
+}
+
+// Step выполняет один шаг синхронизации: полную загрузку, если позиции еще нет, иначе применение изменений
+func (s *Syncer[T]) Step(ctx context.Context) (Stats, error) {
+	_, ok, err := s.store.Load(ctx, s.opts.Key)
+	if err != nil {
+		return Stats{}, err
+	}
+	if !ok {
+		return s.load(ctx)
+	}
+	return s.delta(ctx)
+}
+
+// load загружает все элементы и сохраняет позицию по самому позднему __updatedAt загруженных элементов
+// (время сервера, а не локальное) за вычетом loadOverlap: изменения, сделанные во время загрузки,
+// будут получены следующим шагом. Если под фильтр не подошел ни один элемент, позиция определяется
+// по последнему изменению в приложении, чтобы следующий шаг не перебирал всю историю изменений
+func (s *Syncer[T]) load(ctx context.Context) (Stats, error) {
+
+	stats := Stats{}
+	watermark := time.Unix(0, 0).UTC()
+	err := s.app.Search().Where(s.opts.Filter).ScanEach(ctx, e365_gateway.ScanOptions{}, func(items []T) error {
+		records := make([]Record, 0, len(items))
+		for _, item := range items {
+			r, err := newRecord(item)
+			if err != nil {
+				return err
+			}
+			if r.UpdatedAt.After(watermark) {
+				watermark = r.UpdatedAt
+			}
+			records = append(records, r)
+		}
+		stats.Loaded += len(records)
+		return s.store.Put(ctx, records...)
+	})
+	if err != nil {
+		return stats, err
+	}
+	if stats.Loaded == 0 {
+		latest, err := s.app.Search().IncludeDeleted().OrderByDesc("__updatedAt").First(ctx)
+		if err != nil {
+			return stats, err
+		}
+		r, err := newRecord(latest)
+		if err != nil {
+			return stats, err
+		}
+		if r.UpdatedAt.After(watermark) {
+			watermark = r.UpdatedAt
+		}
+	}
+	if watermark.After(time.Unix(0, 0)) {
+		watermark = watermark.Add(-loadOverlap)
+	}
+
+	return stats, s.store.Save(ctx, s.opts.Key, e365_gateway.Checkpoint{Watermark: watermark})
+}
+
+// delta применяет изменения с последней сохраненной позиции
+func (s *Syncer[T]) delta(ctx context.Context) (Stats, error) {
+
+	stats := Stats{}
+	filtered := !reflect.ValueOf(s.opts.Filter).IsZero()
+	err := s.app.PollBatch(ctx, e365_gateway.SearchFilter{}, e365_gateway.WatchOptions{Store: s.store, Key: s.opts.Key}, func(events []e365_gateway.Event[T]) error {
+		// изменения, которых еще нет в хранилище (часть могла быть получена полной загрузкой)
+		changes := make([]change[T], 0, len(events))
+		check := make([]string, 0, len(events))
+		for _, e := range events {
+			r, err := newRecord(e.Item)
+			if err != nil {
+				return err
+			}
+			prev, stored, err := s.store.Get(ctx, r.ID)
+			if err != nil {
+				return err
+			}
+			if stored && prev.Version == r.Version && prev.Deleted == r.Deleted {
+				continue
+			}
+			changes = append(changes, change[T]{event: e, record: r, stored: stored})
+			if filtered && e.Type != e365_gateway.EventDeleted {
+				check = append(check, r.ID)
+			}
+		}
+		matched, err := s.matching(ctx, check)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			e, r := c.event, c.record
+			remove := e.Type == e365_gateway.EventDeleted && !s.opts.KeepDeleted
+			if filtered && e.Type != e365_gateway.EventDeleted {
+				_, ok := matched[r.ID]
+				remove = !ok
+			}
+			if remove {
+				if !c.stored {
+					continue
+				}
+				stats.Deleted++
+				if err = s.store.Delete(ctx, r.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			switch e.Type {
+			case e365_gateway.EventDeleted:
+				stats.Deleted++
+			case e365_gateway.EventCreated:
+				stats.Created++
+			default:
+				stats.Updated++
+			}
+			if err = s.store.Put(ctx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// change - изменение элемента и наличие его записи в хранилище
+type change[T interface{}] struct {
+	event  e365_gateway.Event[T]
+	record Record
+	stored bool
+}
+
+// matching возвращает id элементов из ids, которые подходят под Options.Filter (один запрос на пачку изменений)
+func (s *Syncer[T]) matching(ctx context.Context, ids []string) (map[string]struct{}, error) {
+	matched := make(map[string]struct{}, len(ids))
+	f := s.opts.Filter
+	if len(f.IDs) > 0 {
+		ids = slices.DeleteFunc(slices.Clone(ids), func(id string) bool {
+			return !slices.Contains(f.IDs, id)
+		})
+	}
+	if len(ids) == 0 {
+		return matched, nil
+	}
+	f.IDs = ids
+	items, err := s.app.Search().Where(f).IncludeDeleted().Size(len(ids)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		r, err := newRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		matched[r.ID] = struct{}{}
+	}
+	return matched, nil
+}
+
+// newRecord кодирует элемент приложения в запись хранилища
+func newRecord(item interface{}) (Record, error) {
+	bts, err := json.Marshal(item)
+	if err != nil {
+		return Record{}, err
+	}
+	var c e365_gateway.AppCommon
+	if err = json.Unmarshal(bts, &c); err != nil {
+		return Record{}, err
+	}
+	return Record{
+		ID:        c.ID,
+		Version:   c.Version,
+		UpdatedAt: c.UpdatedAt,
+		Deleted:   !c.DeletedAt.IsZero(),
+		Data:      bts,
+	}, nil
+}
@@ -0,0 +1,211 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	e365_gateway "github.com/inse91/elma_lib"
+	"github.com/inse91/elma_lib/internal/elmatest"
+	"github.com/stretchr/testify/require"
+)
+
+type product struct {
+	e365_gateway.AppCommon
+	Price int `json:"price"`
+}
+
+func TestSyncer(t *testing.T) {
+
+	now := time.Now().UTC()
+	st := elmatest.NewStand(t, "")
+	fa := st.App("ns", "app")
+	for i := 0; i < 230; i++ {
+		item := elmatest.Item(elmatest.ID(i), now.Add(-time.Hour+time.Duration(i)*time.Second))
+		item["price"] = float64(i)
+		fa.Items = append(fa.Items, item)
+	}
+	app := e365_gateway.NewApp[product](e365_gateway.Settings{
+		Stand:     e365_gateway.NewStand(e365_gateway.StandConfig{Host: st.URL}),
+		Namespace: "ns",
+		Code:      "app",
+	})
+	ctxBg := context.Background()
+	edit := func(n int, fields map[string]interface{}) {
+		st.Lock()
+		defer st.Unlock()
+		item := fa.Items[n]
+		for k, v := range fields {
+			item[k] = v
+		}
+		item["__version"] = item["__version"].(float64) + 1
+		item["__updatedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	require.NoError(t, err)
+	syncer := New(app, store, Options{})
+
+	stats, err := syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Loaded: 230}, stats)
+	require.Equal(t, 230, store.Len())
+
+	edit(10, map[string]interface{}{"price": float64(1000)})
+	edit(20, map[string]interface{}{"__deletedAt": time.Now().UTC().Format(time.RFC3339Nano)})
+	st.Lock()
+	st.Add(fa, elmatest.Item(elmatest.ID(500), time.Now()))
+	st.Unlock()
+
+	stats, err = syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Created: 1, Updated: 1, Deleted: 1}, stats)
+
+	p, ok, err := Get[product](ctxBg, store, elmatest.ID(10))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1000, p.Price)
+	_, ok, err = Get[product](ctxBg, store, elmatest.ID(20))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	stats, err = syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Zero(t, stats.Created+stats.Deleted)
+
+	// после перезапуска хранилище восстанавливается из журнала, синхронизация продолжается без полной загрузки
+	require.NoError(t, store.Close())
+	f, err := os.OpenFile(filepath.Join(dir, fileRecords), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"put":{"id":"broken`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = OpenFileStore(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	require.Equal(t, 230, store.Len())
+
+	edit(30, map[string]interface{}{"price": float64(3000)})
+	stats, err = New(app, store, Options{}).Step(ctxBg)
+	require.NoError(t, err)
+	require.Zero(t, stats.Loaded)
+	require.GreaterOrEqual(t, stats.Updated, 1)
+
+	require.NoError(t, store.Compact(ctxBg))
+	store2, err := OpenFileStore(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store2.Close() })
+
+	total := 0
+	require.NoError(t, Each[product](ctxBg, store2, func(p product) error {
+		total++
+		if p.ID == elmatest.ID(30) {
+			require.Equal(t, 3000, p.Price)
+		}
+		return nil
+	}))
+	require.Equal(t, 230, total)
+
+}
+
+func TestDeletedRecord(t *testing.T) {
+
+	store := NewMemoryStore()
+	ctxBg := context.Background()
+	r, err := newRecord(product{AppCommon: e365_gateway.AppCommon{ID: elmatest.ID(1), Version: 3, DeletedAt: time.Now()}, Price: 1})
+	require.NoError(t, err)
+	require.True(t, r.Deleted)
+	require.Equal(t, 3, r.Version)
+	require.NoError(t, store.Put(ctxBg, r))
+
+	total := 0
+	require.NoError(t, Each[product](ctxBg, store, func(p product) error {
+		total++
+		return nil
+	}))
+	require.Zero(t, total)
+	_, ok, err := Get[product](ctxBg, store, elmatest.ID(1))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+}
+
+func TestSyncerFilter(t *testing.T) {
+
+	now := time.Now().UTC()
+	st := elmatest.NewStand(t, "")
+	fa := st.App("ns", "app")
+	for i := 0; i < 30; i++ {
+		item := elmatest.Item(elmatest.ID(i), now.Add(-time.Hour+time.Duration(i)*time.Second))
+		item["price"] = float64(i)
+		fa.Items = append(fa.Items, item)
+	}
+	app := e365_gateway.NewApp[product](e365_gateway.Settings{
+		Stand:     e365_gateway.NewStand(e365_gateway.StandConfig{Host: st.URL}),
+		Namespace: "ns",
+		Code:      "app",
+	})
+	ctxBg := context.Background()
+	setPrice := func(n int, price float64) {
+		st.Lock()
+		defer st.Unlock()
+		item := fa.Items[n]
+		item["price"] = price
+		item["__version"] = item["__version"].(float64) + 1
+		item["__updatedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	store := NewMemoryStore()
+	syncer := New(app, store, Options{
+		Filter: e365_gateway.SearchFilter{Fields: e365_gateway.Fields{"price": e365_gateway.Field.Number().From(10)}},
+	})
+	stats, err := syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Loaded: 20}, stats)
+
+	// позиция - время сервера последнего загруженного элемента, а не локальное время загрузки
+	cp, ok, err := store.Load(ctxBg, defaultKey)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, now.Add(-time.Hour+29*time.Second-loadOverlap), cp.Watermark)
+
+	setPrice(15, 5)
+	setPrice(3, 50)
+	lists := fa.Hits["/list"]
+	stats, err = syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Updated: 1, Deleted: 1}, stats)
+	// одна страница изменений и одна проверка фильтра для всей пачки, а не запрос на каждый элемент
+	require.Equal(t, lists+2, fa.Hits["/list"])
+
+	_, ok, err = Get[product](ctxBg, store, elmatest.ID(15))
+	require.NoError(t, err)
+	require.False(t, ok)
+	p, ok, err := Get[product](ctxBg, store, elmatest.ID(3))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 50, p.Price)
+
+	stats, err = syncer.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{}, stats)
+
+	// под фильтр не подходит ни один элемент: позиция - последнее изменение в приложении, а не 1970 год
+	empty := New(app, store, Options{
+		Key:    "empty",
+		Filter: e365_gateway.SearchFilter{Fields: e365_gateway.Fields{"price": e365_gateway.Field.Number().From(1000)}},
+	})
+	stats, err = empty.Step(ctxBg)
+	require.NoError(t, err)
+	require.Equal(t, Stats{}, stats)
+	latest, err := time.Parse(time.RFC3339Nano, fa.Items[3]["__updatedAt"].(string))
+	require.NoError(t, err)
+	cp, ok, err = store.Load(ctxBg, "empty")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, latest.Add(-loadOverlap).Equal(cp.Watermark), cp.Watermark)
+}
@@ -0,0 +1,94 @@
+package types
+
+import "encoding/json"
+
+// Category - вариант поля типа "Категория"
+type Category struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// FilterValue - значение для фильтров поиска: код варианта
+func (c Category) FilterValue() interface{} {
+	return c.Code
+}
+
+func (c Category) MarshalJSON() ([]byte, error) {
+	if c.Code == "" {
+		return null, nil
+	}
+	return json.Marshal([]category{category(c)})
+}
+
+// UnmarshalJSON декодирует как одиночный вариант, так и массив (берется первый элемент)
+func (c *Category) UnmarshalJSON(bts []byte) error {
+	var cs Categories
+	if err := cs.UnmarshalJSON(bts); err != nil {
+		return err
+	}
+	*c = Category{}
+	if len(cs) > 0 {
+		*c = cs[0]
+	}
+	return nil
+}
+
+type category Category
+
+// Categories - значение поля типа "Категория" с несколькими вариантами
+type Categories []Category
+
+// Codes возвращает коды вариантов
+func (cs Categories) Codes() []string {
+	codes := make([]string, 0, len(cs))
+	for _, c := range cs {
+		codes = append(codes, c.Code)
+	}
+	return codes
+}
+
+// Has проверяет, что среди вариантов есть вариант с кодом code
+func (cs Categories) Has(code string) bool {
+	for _, c := range cs {
+		if c.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterValue - значение для фильтров поиска: коды вариантов
+func (cs Categories) FilterValue() interface{} {
+	return cs.Codes()
+}
+
+func (cs Categories) MarshalJSON() ([]byte, error) {
+	if len(cs) == 0 {
+		return null, nil
+	}
+	res := make([]category, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, category(c))
+	}
+	return json.Marshal(res)
+}
+
+func (cs *Categories) UnmarshalJSON(bts []byte) error {
+	*cs = nil
+	if isNull(bts) {
+		return nil
+	}
+	var single category
+	if err := json.Unmarshal(bts, &single); err == nil {
+		*cs = Categories{Category(single)}
+		return nil
+	}
+	res := make([]category, 0)
+	if err := json.Unmarshal(bts, &res); err != nil {
+		return err
+	}
+	for _, c := range res {
+		*cs = append(*cs, Category(c))
+	}
+	return nil
+}
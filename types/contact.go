@@ -0,0 +1,128 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PhoneType - тип номера телефона
+type PhoneType string
+
+const (
+	PhoneMain   PhoneType = "main"
+	PhoneWork   PhoneType = "work"
+	PhoneMobile PhoneType = "mobile"
+	PhoneHome   PhoneType = "home"
+)
+
+// Phone - номер телефона с типом
+type Phone struct {
+	Type PhoneType `json:"type"`
+	Tel  string    `json:"tel"`
+}
+
+// FilterValue - значение для фильтров поиска: номер телефона
+func (p Phone) FilterValue() interface{} {
+	return p.Tel
+}
+
+// Phones - значение поля типа "Телефон" (elma365 всегда хранит его как массив)
+type Phones []Phone
+
+func (ps Phones) MarshalJSON() ([]byte, error) {
+	if len(ps) == 0 {
+		return null, nil
+	}
+	return json.Marshal([]Phone(ps))
+}
+
+// First возвращает первый номер или пустую строку
+func (ps Phones) First() string {
+	if len(ps) == 0 {
+		return ""
+	}
+	return ps[0].Tel
+}
+
+// EmailType - тип адреса электронной почты
+type EmailType string
+
+const (
+	EmailMain EmailType = "main"
+	EmailWork EmailType = "work"
+	EmailHome EmailType = "home"
+)
+
+// Email - адрес электронной почты с типом
+type Email struct {
+	Type  EmailType `json:"type"`
+	Email string    `json:"email"`
+}
+
+// FilterValue - значение для фильтров поиска: адрес
+func (e Email) FilterValue() interface{} {
+	return e.Email
+}
+
+// Emails - значение поля типа "Email" (elma365 всегда хранит его как массив)
+type Emails []Email
+
+func (es Emails) MarshalJSON() ([]byte, error) {
+	if len(es) == 0 {
+		return null, nil
+	}
+	return json.Marshal([]Email(es))
+}
+
+// First возвращает первый адрес или пустую строку
+func (es Emails) First() string {
+	if len(es) == 0 {
+		return ""
+	}
+	return es[0].Email
+}
+
+// FullName - значение поля типа "ФИО"
+type FullName struct {
+	Firstname  string `json:"firstname"`
+	Lastname   string `json:"lastname"`
+	Middlename string `json:"middlename"`
+}
+
+type fullName FullName
+
+// IsZero проверяет, что ФИО не заполнено
+func (fn FullName) IsZero() bool {
+	return fn == FullName{}
+}
+
+// String возвращает ФИО в порядке "Фамилия Имя Отчество"
+func (fn FullName) String() string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{fn.Lastname, fn.Firstname, fn.Middlename} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// FilterValue - значение для фильтров поиска: ФИО одной строкой
+func (fn FullName) FilterValue() interface{} {
+	return fn.String()
+}
+
+func (fn FullName) MarshalJSON() ([]byte, error) {
+	if fn.IsZero() {
+		return null, nil
+	}
+	return json.Marshal(fullName(fn))
+}
+
+func (fn *FullName) UnmarshalJSON(bts []byte) error {
+	*fn = FullName{}
+	if isNull(bts) {
+		return nil
+	}
+	return json.Unmarshal(bts, (*fullName)(fn))
+}
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const dateLayout = time.DateOnly
+
+// Date - значение поля типа "Дата" без времени.
+// elma365 может передавать дату как начало дня в поясе стенда со временем (RFC3339), например "2023-07-31T21:00:00Z"
+// для 1 августа по Москве. Тогда Year, Month и Day содержат день в поясе из значения, а день в поясе стенда
+// возвращает In
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+	// at - момент времени, если дата пришла со временем
+	at time.Time
+}
+
+// NewDate возвращает дату из time.Time (в часовом поясе t)
+func NewDate(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// ParseDate разбирает дату в формате "2006-01-02"
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return NewDate(t), nil
+}
+
+// IsZero проверяет, что дата не заполнена
+func (d Date) IsZero() bool {
+	return d.Year == 0 && d.Month == 0 && d.Day == 0
+}
+
+// In возвращает день в часовом поясе loc (например, поясе стенда), если дата пришла со временем.
+// Дата без времени возвращается как есть
+func (d Date) In(loc *time.Location) Date {
+	if d.at.IsZero() || loc == nil {
+		return d
+	}
+	in := NewDate(d.at.In(loc))
+	in.at = d.at
+	return in
+}
+
+// Time возвращает начало дня в часовом поясе loc
+func (d Date) Time(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Time(time.UTC).Format(dateLayout)
+}
+
+// FilterValue - значение для фильтров поиска
+func (d Date) FilterValue() interface{} {
+	return d.String()
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return null, nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON принимает как дату "2006-01-02", так и дату со временем в формате RFC3339
+// (день определяется в поясе из значения, см. In)
+func (d *Date) UnmarshalJSON(bts []byte) error {
+	*d = Date{}
+	if isNull(bts) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(bts, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	if len(s) > len(dateLayout) {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		*d = NewDate(t)
+		d.at = t
+		return nil
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Duration - значение поля типа "Длительность" (в elma365 хранится в миллисекундах)
+type Duration time.Duration
+
+// Std возвращает значение как time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// FilterValue - значение для фильтров поиска: кол-во миллисекунд
+func (d Duration) FilterValue() interface{} {
+	return time.Duration(d).Milliseconds()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	if d == 0 {
+		return null, nil
+	}
+	return []byte(strconv.FormatInt(time.Duration(d).Milliseconds(), 10)), nil
+}
+
+func (d *Duration) UnmarshalJSON(bts []byte) error {
+	*d = 0
+	if isNull(bts) {
+		return nil
+	}
+	var ms float64
+	if err := json.Unmarshal(bts, &ms); err != nil {
+		return err
+	}
+	*d = Duration(time.Duration(ms * float64(time.Millisecond)))
+	return nil
+}
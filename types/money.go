@@ -0,0 +1,63 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Money - значение поля типа "Деньги": сумма в копейках (центах) и код валюты
+type Money struct {
+	Cents    int64  `json:"cents"`
+	Currency string `json:"currency"`
+}
+
+type money Money
+
+// NewMoney создает сумму из десятичного значения, например NewMoney(12.34, "RUB")
+func NewMoney(amount float64, currency string) Money {
+	return Money{Cents: int64(math.Round(amount * 100)), Currency: currency}
+}
+
+// Decimal возвращает сумму в основных единицах валюты (рублях, долларах и т.д.)
+func (m Money) Decimal() float64 {
+	return float64(m.Cents) / 100
+}
+
+// IsZero проверяет, что сумма не заполнена
+func (m Money) IsZero() bool {
+	return m == Money{}
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	if m.Currency == "" {
+		return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, m.Currency)
+}
+
+// FilterValue - значение для фильтров поиска: сумма в основных единицах валюты
+func (m Money) FilterValue() interface{} {
+	return m.Decimal()
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.IsZero() {
+		return null, nil
+	}
+	return json.Marshal(money(m))
+}
+
+func (m *Money) UnmarshalJSON(bts []byte) error {
+	*m = Money{}
+	if isNull(bts) {
+		return nil
+	}
+	return json.Unmarshal(bts, (*money)(m))
+}
@@ -0,0 +1,217 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// TableRow - служебные поля строки таблицы. Встраивается в структуру строки, чтобы Table могла
+// сохранять id строк при добавлении, изменении и удалении
+type TableRow struct {
+	ID string `json:"__id,omitempty"`
+}
+
+func (r *TableRow) tableRow() *TableRow {
+	return r
+}
+
+type tableRower interface {
+	tableRow() *TableRow
+}
+
+// Table - значение поля типа "Таблица": строки типа Row и итоговая строка Result.
+// Итоги по числовым колонкам (числа и Money) пересчитываются через Compute и при кодировании в json
+type Table[Row interface{}] struct {
+	Rows   []Row
+	Result map[string]interface{}
+}
+
+type table[Row interface{}] struct {
+	Rows   []Row                  `json:"rows"`
+	Result map[string]interface{} `json:"result"`
+}
+
+// NewTable создает таблицу с переданными строками (строкам без id назначаются новые)
+func NewTable[Row interface{}](rows ...Row) Table[Row] {
+	t := Table[Row]{}
+	t.Append(rows...)
+	return t
+}
+
+// Len возвращает кол-во строк
+func (t *Table[Row]) Len() int {
+	return len(t.Rows)
+}
+
+// Append добавляет строки в конец таблицы, назначая новые id строкам без id
+func (t *Table[Row]) Append(rows ...Row) {
+	for _, row := range rows {
+		if r, ok := any(&row).(tableRower); ok && r.tableRow().ID == "" {
+			r.tableRow().ID = uuid.New().String()
+		}
+		t.Rows = append(t.Rows, row)
+	}
+}
+
+// Find возвращает строку с переданным id
+func (t *Table[Row]) Find(id string) (Row, bool) {
+	if i := t.index(id); i >= 0 {
+		return t.Rows[i], true
+	}
+	var nilRow Row
+	return nilRow, false
+}
+
+// Update изменяет строку с переданным id через fn (id строки сохраняется). Возвращает false, если строки нет
+func (t *Table[Row]) Update(id string, fn func(row *Row)) bool {
+	i := t.index(id)
+	if i < 0 {
+		return false
+	}
+	fn(&t.Rows[i])
+	if r, ok := any(&t.Rows[i]).(tableRower); ok {
+		r.tableRow().ID = id
+	}
+	return true
+}
+
+// Remove удаляет строку с переданным id. Возвращает false, если строки нет
+func (t *Table[Row]) Remove(id string) bool {
+	i := t.index(id)
+	if i < 0 {
+		return false
+	}
+	t.Rows = append(t.Rows[:i:i], t.Rows[i+1:]...)
+	return true
+}
+
+func (t *Table[Row]) index(id string) int {
+	if id == "" {
+		return -1
+	}
+	for i := range t.Rows {
+		if r, ok := any(&t.Rows[i]).(tableRower); ok && r.tableRow().ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Compute пересчитывает итоговую строку: суммы по колонкам с числами и Money.
+// Остальные значения Result сохраняются
+func (t *Table[Row]) Compute() map[string]interface{} {
+	result := make(map[string]interface{}, len(t.Result))
+	for k, v := range t.Result {
+		result[k] = v
+	}
+
+	rowType := reflect.TypeOf((*Row)(nil)).Elem()
+	for _, col := range numericColumns(rowType) {
+		var (
+			sumFloat float64
+			sumMoney Money
+		)
+		for i := range t.Rows {
+			v := reflect.ValueOf(&t.Rows[i]).Elem().FieldByIndex(col.index)
+			switch {
+			case col.money:
+				m := v.Interface().(Money)
+				sumMoney.Cents += m.Cents
+				if sumMoney.Currency == "" {
+					sumMoney.Currency = m.Currency
+				}
+			case v.CanInt():
+				sumFloat += float64(v.Int())
+			case v.CanUint():
+				sumFloat += float64(v.Uint())
+			default:
+				sumFloat += v.Float()
+			}
+		}
+		if col.money {
+			result[col.code] = sumMoney
+			continue
+		}
+		result[col.code] = sumFloat
+	}
+
+	t.Result = result
+	return result
+}
+
+func (t Table[Row]) MarshalJSON() ([]byte, error) {
+	if t.Rows == nil && t.Result == nil {
+		return null, nil
+	}
+	rows := t.Rows
+	if rows == nil {
+		rows = []Row{}
+	}
+	return json.Marshal(table[Row]{Rows: rows, Result: t.Compute()})
+}
+
+func (t *Table[Row]) UnmarshalJSON(bts []byte) error {
+	*t = Table[Row]{}
+	if isNull(bts) {
+		return nil
+	}
+	var decoded table[Row]
+	if err := json.Unmarshal(bts, &decoded); err != nil {
+		return err
+	}
+	t.Rows = decoded.Rows
+	t.Result = decoded.Result
+	return nil
+}
+
+type tableColumn struct {
+	code  string
+	index []int
+	money bool
+}
+
+var (
+	moneyType     = reflect.TypeOf(Money{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// numericColumns находит колонки строки, по которым считаются итоги
+func numericColumns(t reflect.Type) []tableColumn {
+	cols := make([]tableColumn, 0)
+	if t.Kind() != reflect.Struct {
+		return cols
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		code, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if code == "-" {
+			continue
+		}
+		if code == "" {
+			code = sf.Name
+		}
+		col := tableColumn{code: code, index: sf.Index}
+		if sf.Type != moneyType && sf.Type.Implements(marshalerType) {
+			// значения со своим json представлением (например, Duration) не суммируются
+			continue
+		}
+		switch sf.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			cols = append(cols, col)
+		case reflect.Struct:
+			if sf.Type == moneyType {
+				col.money = true
+				cols = append(cols, col)
+			}
+		}
+	}
+	return cols
+}
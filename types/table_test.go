@@ -0,0 +1,82 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type invoiceRow struct {
+	TableRow
+	Name   string   `json:"name"`
+	Qty    int      `json:"qty"`
+	Price  Money    `json:"price"`
+	Weight float64  `json:"weight"`
+	Time   Duration `json:"time"`
+}
+
+func TestTable(t *testing.T) {
+
+	t.Run("decode_modify_encode", func(t *testing.T) {
+		src := `{
+			"rows": [
+				{"__id": "r1", "name": "a", "qty": 2, "price": {"cents": 1000, "currency": "RUB"}, "weight": 1.5, "time": null},
+				{"__id": "r2", "name": "b", "qty": 3, "price": {"cents": 250, "currency": "RUB"}, "weight": 0.5, "time": null}
+			],
+			"result": {"qty": 5, "note": "total"}
+		}`
+
+		var tbl Table[invoiceRow]
+		require.NoError(t, json.Unmarshal([]byte(src), &tbl))
+		require.Equal(t, 2, tbl.Len())
+		require.Equal(t, "total", tbl.Result["note"])
+
+		require.True(t, tbl.Update("r2", func(row *invoiceRow) {
+			row.Qty = 10
+			row.ID = "changed"
+		}))
+		row, ok := tbl.Find("r2")
+		require.True(t, ok)
+		require.Equal(t, 10, row.Qty)
+
+		tbl.Append(invoiceRow{Name: "c", Qty: 1, Price: NewMoney(5, "RUB")})
+		require.Len(t, tbl.Rows[2].ID, 36)
+
+		require.True(t, tbl.Remove("r1"))
+		require.False(t, tbl.Remove("r1"))
+		require.False(t, tbl.Update("r1", func(row *invoiceRow) {}))
+
+		bts, err := json.Marshal(tbl)
+		require.NoError(t, err)
+
+		var encoded struct {
+			Rows   []map[string]interface{} `json:"rows"`
+			Result map[string]interface{}   `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(bts, &encoded))
+		require.Len(t, encoded.Rows, 2)
+		require.Equal(t, "r2", encoded.Rows[0]["__id"])
+		require.Equal(t, float64(11), encoded.Result["qty"])
+		require.Equal(t, 0.5, encoded.Result["weight"])
+		require.Equal(t, map[string]interface{}{"cents": float64(750), "currency": "RUB"}, encoded.Result["price"])
+		require.Equal(t, "total", encoded.Result["note"])
+		require.NotContains(t, encoded.Result, "time")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		bts, err := json.Marshal(Table[invoiceRow]{})
+		require.NoError(t, err)
+		require.Equal(t, "null", string(bts))
+
+		tbl := NewTable[invoiceRow]()
+		tbl.Result = map[string]interface{}{}
+		bts, err = json.Marshal(tbl)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"rows": [], "result": {"qty": 0, "weight": 0, "price": null}}`, string(bts))
+
+		require.NoError(t, json.Unmarshal([]byte("null"), &tbl))
+		require.Zero(t, tbl.Len())
+	})
+
+}
@@ -0,0 +1,41 @@
+// Package types содержит типы значений полей elma365 (деньги, телефон, email, ФИО, категория, пользователь,
+// дата без времени, длительность) с правильным json представлением.
+// Нулевые значения кодируются как null, а null декодируется в нулевое значение.
+// Значения можно передавать в фильтры поиска: они реализуют FilterValue.
+package types
+
+import "encoding/json"
+
+var null = []byte("null")
+
+// isNull проверяет, что в json передан null
+func isNull(bts []byte) bool {
+	return string(bts) == string(null)
+}
+
+// marshalRefs кодирует список id как массив (пустой список - как null)
+func marshalRefs(ids []string) ([]byte, error) {
+	if len(ids) == 0 {
+		return null, nil
+	}
+	return json.Marshal(ids)
+}
+
+// unmarshalRefs декодирует null, строку или массив id
+func unmarshalRefs(bts []byte) ([]string, error) {
+	if isNull(bts) {
+		return nil, nil
+	}
+	var id string
+	if err := json.Unmarshal(bts, &id); err == nil {
+		if id == "" {
+			return nil, nil
+		}
+		return []string{id}, nil
+	}
+	ids := make([]string, 0)
+	if err := json.Unmarshal(bts, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
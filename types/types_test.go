@@ -0,0 +1,115 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type contact struct {
+	Budget   Money      `json:"budget"`
+	Phone    Phones     `json:"phone"`
+	Email    Emails     `json:"email"`
+	FullName FullName   `json:"fullname"`
+	Kind     Category   `json:"kind"`
+	Tags     Categories `json:"tags"`
+	Manager  UserRef    `json:"manager"`
+	Team     UserRefs   `json:"team"`
+	Birthday Date       `json:"birthday"`
+	Estimate Duration   `json:"estimate"`
+}
+
+func TestTypes(t *testing.T) {
+
+	t.Run("round_trip", func(t *testing.T) {
+		src := `{
+			"budget": {"cents": 123456, "currency": "RUB"},
+			"phone": [{"type": "mobile", "tel": "+79990000000"}],
+			"email": [{"type": "work", "email": "a@b.c"}],
+			"fullname": {"firstname": "Иван", "lastname": "Иванов", "middlename": "Иванович"},
+			"kind": [{"code": "vip", "name": "VIP"}],
+			"tags": [{"code": "a", "name": "A"}, {"code": "b", "name": "B"}],
+			"manager": ["018a2b9f-003d-2b48-7e2a-324e6fc16db8"],
+			"team": ["018a2b9f-003d-2b48-7e2a-324e6fc16db8", "018a2b9f-003d-2b48-7e2a-324e6fc16db9"],
+			"birthday": "1990-05-17",
+			"estimate": 5400000
+		}`
+
+		var c contact
+		require.NoError(t, json.Unmarshal([]byte(src), &c))
+		require.Equal(t, 1234.56, c.Budget.Decimal())
+		require.Equal(t, "1234.56 RUB", c.Budget.String())
+		require.Equal(t, "+79990000000", c.Phone.First())
+		require.Equal(t, "a@b.c", c.Email.First())
+		require.Equal(t, "Иванов Иван Иванович", c.FullName.String())
+		require.Equal(t, "vip", c.Kind.Code)
+		require.Equal(t, []string{"a", "b"}, c.Tags.Codes())
+		require.True(t, c.Tags.Has("b"))
+		require.Equal(t, "018a2b9f-003d-2b48-7e2a-324e6fc16db8", c.Manager.ID)
+		require.Len(t, c.Team, 2)
+		require.Equal(t, Date{Year: 1990, Month: time.May, Day: 17}, c.Birthday)
+		require.Equal(t, 90*time.Minute, c.Estimate.Std())
+
+		bts, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.JSONEq(t, src, string(bts))
+	})
+
+	t.Run("zero_values", func(t *testing.T) {
+		bts, err := json.Marshal(contact{})
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"budget": null, "phone": null, "email": null, "fullname": null, "kind": null,
+			"tags": null, "manager": null, "team": null, "birthday": null, "estimate": null
+		}`, string(bts))
+
+		c := contact{Budget: NewMoney(1, "RUB"), Birthday: NewDate(time.Now())}
+		require.NoError(t, json.Unmarshal(bts, &c))
+		require.Equal(t, contact{}, c)
+	})
+
+	t.Run("lenient_decoding", func(t *testing.T) {
+		var c contact
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"kind": {"code": "vip", "name": "VIP"},
+			"tags": {"code": "a", "name": "A"},
+			"manager": "018a2b9f-003d-2b48-7e2a-324e6fc16db8",
+			"birthday": "1990-05-16T21:00:00Z"
+		}`), &c))
+		require.Equal(t, "vip", c.Kind.Code)
+		require.Equal(t, []string{"a"}, c.Tags.Codes())
+		require.Equal(t, "018a2b9f-003d-2b48-7e2a-324e6fc16db8", c.Manager.ID)
+		require.Equal(t, "1990-05-17", c.Birthday.In(time.FixedZone("MSK", 3*60*60)).String())
+	})
+
+	t.Run("date_location", func(t *testing.T) {
+		// полночь по Москве приходит как 21:00 предыдущего дня по UTC
+		msk := time.FixedZone("MSK", 3*60*60)
+		day := func(d Date) Date {
+			return Date{Year: d.Year, Month: d.Month, Day: d.Day}
+		}
+		var d Date
+		require.NoError(t, json.Unmarshal([]byte(`"2023-07-31T21:00:00Z"`), &d))
+		require.Equal(t, Date{Year: 2023, Month: time.July, Day: 31}, day(d))
+		require.Equal(t, Date{Year: 2023, Month: time.August, Day: 1}, day(d.In(msk)))
+		require.NoError(t, json.Unmarshal([]byte(`"2023-08-01T00:00:00+03:00"`), &d))
+		require.Equal(t, Date{Year: 2023, Month: time.August, Day: 1}, day(d))
+		require.Equal(t, Date{Year: 2023, Month: time.August, Day: 1}, day(d.In(msk)))
+
+		require.NoError(t, json.Unmarshal([]byte(`"2023-08-01T05:00:00Z"`), &d))
+		require.Equal(t, Date{Year: 2023, Month: time.August, Day: 1}, day(d.In(time.FixedZone("UTC-5", -5*60*60))))
+
+		// дата без времени не зависит от пояса
+		require.NoError(t, json.Unmarshal([]byte(`"2023-08-01"`), &d))
+		require.Equal(t, Date{Year: 2023, Month: time.August, Day: 1}, d.In(msk))
+	})
+
+	t.Run("money", func(t *testing.T) {
+		require.Equal(t, int64(1999), NewMoney(19.99, "USD").Cents)
+		require.Equal(t, "-0.05", Money{Cents: -5}.String())
+		require.Equal(t, 19.99, NewMoney(19.99, "USD").FilterValue())
+	})
+
+}
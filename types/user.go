@@ -0,0 +1,51 @@
+package types
+
+// UserRef - значение одиночного поля типа "Пользователи": id пользователя
+type UserRef struct {
+	ID string
+}
+
+// FilterValue - значение для фильтров поиска, аналог Field.App
+func (u UserRef) FilterValue() interface{} {
+	return [1]string{u.ID}
+}
+
+func (u UserRef) MarshalJSON() ([]byte, error) {
+	if u.ID == "" {
+		return null, nil
+	}
+	return marshalRefs([]string{u.ID})
+}
+
+func (u *UserRef) UnmarshalJSON(bts []byte) error {
+	ids, err := unmarshalRefs(bts)
+	if err != nil {
+		return err
+	}
+	*u = UserRef{}
+	if len(ids) > 0 {
+		u.ID = ids[0]
+	}
+	return nil
+}
+
+// UserRefs - значение множественного поля типа "Пользователи": id пользователей
+type UserRefs []string
+
+// FilterValue - значение для фильтров поиска
+func (us UserRefs) FilterValue() interface{} {
+	return []string(us)
+}
+
+func (us UserRefs) MarshalJSON() ([]byte, error) {
+	return marshalRefs(us)
+}
+
+func (us *UserRefs) UnmarshalJSON(bts []byte) error {
+	ids, err := unmarshalRefs(bts)
+	if err != nil {
+		return err
+	}
+	*us = ids
+	return nil
+}